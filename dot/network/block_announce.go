@@ -75,6 +75,30 @@ func (bm *BlockAnnounceMessage) Hash() (common.Hash, error) {
 	return common.Blake2bHash(encMsg)
 }
 
+// Validate checks the announced header is structurally valid: the genesis
+// block cannot be announced, every digest item must be set and the header
+// can contain at most one seal, which must be the last digest item.
+func (bm *BlockAnnounceMessage) Validate() error {
+	if bm.Number == 0 {
+		return fmt.Errorf("%w: genesis block cannot be announced", errBlockAnnounceInvalid)
+	}
+
+	for i, item := range bm.Digest {
+		value, err := item.Value()
+		if err != nil {
+			return fmt.Errorf("%w: digest item %d: %w", errBlockAnnounceInvalid, i, err)
+		}
+
+		_, isSeal := value.(types.SealDigest)
+		if isSeal && i != len(bm.Digest)-1 {
+			return fmt.Errorf("%w: seal digest item %d is not the last of %d items",
+				errBlockAnnounceInvalid, i, len(bm.Digest))
+		}
+	}
+
+	return nil
+}
+
 func decodeBlockAnnounceHandshake(in []byte) (Handshake, error) {
 	hs := BlockAnnounceHandshake{}
 	err := scale.Unmarshal(in, &hs)
@@ -209,6 +233,15 @@ func (s *Service) handleBlockAnnounceMessage(from peer.ID, msg NotificationsMess
 		return false, errors.New("invalid message")
 	}
 
+	err = bam.Validate()
+	if err != nil {
+		s.host.cm.peerSetHandler.ReportPeer(peerset.ReputationChange{
+			Value:  peerset.BadBlockAnnouncementValue,
+			Reason: peerset.BadBlockAnnouncementReason,
+		}, from)
+		return false, err
+	}
+
 	err = s.syncer.HandleBlockAnnounce(from, bam)
 	if errors.Is(err, blocktree.ErrBlockExists) {
 		return true, nil
@@ -66,3 +66,92 @@ func Test_BlockAnnounceMessage_String(t *testing.T) {
 		})
 	}
 }
+
+func Test_BlockAnnounceMessage_Validate(t *testing.T) {
+	t.Parallel()
+
+	preRuntimeDigest := types.PreRuntimeDigest{
+		ConsensusEngineID: types.BabeEngineID,
+		Data:              []byte{1},
+	}
+	sealDigest := types.SealDigest{
+		ConsensusEngineID: types.BabeEngineID,
+		Data:              []byte{2},
+	}
+
+	newDigest := func(t *testing.T, values ...any) types.Digest {
+		t.Helper()
+		digest := types.NewDigest()
+		err := digest.Add(values...)
+		require.NoError(t, err)
+		return digest
+	}
+
+	testCases := map[string]struct {
+		message    func(t *testing.T) *BlockAnnounceMessage
+		errWrapped error
+		errMessage string
+	}{
+		"valid": {
+			message: func(t *testing.T) *BlockAnnounceMessage {
+				return &BlockAnnounceMessage{
+					ParentHash: common.Hash{1},
+					Number:     1,
+					Digest:     newDigest(t, preRuntimeDigest, sealDigest),
+				}
+			},
+		},
+		"genesis_number": {
+			message: func(t *testing.T) *BlockAnnounceMessage {
+				return &BlockAnnounceMessage{
+					Digest: newDigest(t, preRuntimeDigest, sealDigest),
+				}
+			},
+			errWrapped: errBlockAnnounceInvalid,
+			errMessage: "block announce is not valid: genesis block cannot be announced",
+		},
+		"unset_digest_item": {
+			message: func(t *testing.T) *BlockAnnounceMessage {
+				return &BlockAnnounceMessage{
+					Number: 1,
+					Digest: types.Digest{types.NewDigestItem()},
+				}
+			},
+			errWrapped: errBlockAnnounceInvalid,
+			errMessage: "block announce is not valid: digest item 0: unsupported VaryingDataTypeValue",
+		},
+		"seal_not_last": {
+			message: func(t *testing.T) *BlockAnnounceMessage {
+				return &BlockAnnounceMessage{
+					Number: 1,
+					Digest: newDigest(t, sealDigest, preRuntimeDigest),
+				}
+			},
+			errWrapped: errBlockAnnounceInvalid,
+			errMessage: "block announce is not valid: seal digest item 0 is not the last of 2 items",
+		},
+		"two_seals": {
+			message: func(t *testing.T) *BlockAnnounceMessage {
+				return &BlockAnnounceMessage{
+					Number: 1,
+					Digest: newDigest(t, preRuntimeDigest, sealDigest, sealDigest),
+				}
+			},
+			errWrapped: errBlockAnnounceInvalid,
+			errMessage: "block announce is not valid: seal digest item 1 is not the last of 3 items",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := testCase.message(t).Validate()
+			require.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				require.EqualError(t, err, testCase.errMessage)
+			}
+		})
+	}
+}
@@ -46,6 +46,18 @@
 	// DefaultDiscoveryInterval is the default interval for searching for DHT peers
 	DefaultDiscoveryInterval = time.Minute * 5
 
+	// DefaultBlockRequestRate is the default number of block requests per second
+	// a single peer is allowed to make once its burst is consumed
+	DefaultBlockRequestRate = 4
+
+	// DefaultBlockRequestBurst is the default number of block requests a single
+	// peer can make in a row before being rate limited
+	DefaultBlockRequestBurst = 32
+
+	// DefaultInboundHandshakeTimeout is the default duration to wait for
+	// the first message of an inbound stream
+	DefaultInboundHandshakeTimeout = handshakeTimeout
+
 	defaultTxnBatchSize = 100
 )
 
@@ -93,6 +105,20 @@ type Config struct {
 
 	DiscoveryInterval time.Duration
 
+	// BlockRequestRate is the number of block requests per second a peer is allowed
+	// to make, and BlockRequestBurst the number of requests it can make in a row.
+	// Requests exceeding the rate are refused by resetting their stream.
+	BlockRequestRate  float64
+	BlockRequestBurst uint
+	// NoBlockRequestRateLimit disables the block request rate limit
+	NoBlockRequestRateLimit bool
+
+	// InboundHandshakeTimeout is the maximum duration to wait for the first
+	// message, such as a handshake, of an inbound stream before closing it.
+	// It defaults to DefaultInboundHandshakeTimeout if zero, and a negative
+	// value disables it.
+	InboundHandshakeTimeout time.Duration
+
 	// PersistentPeers is a list of multiaddrs which the node should remain connected to
 	PersistentPeers []string
 
@@ -17,9 +17,13 @@
 	errInvalidHandshakeForPeer       = errors.New("peer previously sent invalid handshake")
 	errHandshakeTimeout              = errors.New("handshake timeout reached")
 	errBlockRequestFromNumberInvalid = errors.New("block request message From number is not valid")
+	errBlockRequestDirectionInvalid  = errors.New("block request message Direction is not valid")
 	errInvalidStartingBlockType      = errors.New("invalid StartingBlock in messsage")
 	errInboundHanshakeExists         = errors.New("an inbound handshake already exists for given peer")
 	errInvalidRole                   = errors.New("invalid role")
+	errBlockRequestRateLimited       = errors.New("block request rate limit exceeded")
+	errBlockAnnounceInvalid          = errors.New("block announce is not valid")
+	errNoHandlerForProtocol          = errors.New("no handler registered for protocol")
 	ErrFailedToReadEntireMessage     = errors.New("failed to read entire message")
 	ErrNilStream                     = errors.New("nil stream")
 	ErrInvalidLEB128EncodedData      = errors.New("invalid LEB128 encoded data")
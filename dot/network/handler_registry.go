@@ -0,0 +1,81 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package network
+
+import (
+	"sync"
+
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// registeredStreamHandler is how the messages received on the streams of a
+// protocol are read, decoded and handled.
+type registeredStreamHandler struct {
+	decoder messageDecoder
+	handler messageHandler
+	maxSize uint64
+}
+
+// StreamHandlerRegistry maps protocol IDs to the handler of the messages
+// received on streams using that protocol.
+type StreamHandlerRegistry struct {
+	mu       sync.RWMutex
+	handlers map[protocol.ID]registeredStreamHandler
+}
+
+// NewStreamHandlerRegistry creates an empty StreamHandlerRegistry.
+func NewStreamHandlerRegistry() *StreamHandlerRegistry {
+	return &StreamHandlerRegistry{
+		handlers: make(map[protocol.ID]registeredStreamHandler),
+	}
+}
+
+// Register sets the decoder and handler of the messages of at most maxSize
+// bytes received for the protocol ID, replacing any previously registered.
+func (r *StreamHandlerRegistry) Register(protocolID protocol.ID, decoder messageDecoder,
+	handler messageHandler, maxSize uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[protocolID] = registeredStreamHandler{
+		decoder: decoder,
+		handler: handler,
+		maxSize: maxSize,
+	}
+}
+
+func (r *StreamHandlerRegistry) lookup(protocolID protocol.ID) (
+	registered registeredStreamHandler, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	registered, ok = r.handlers[protocolID]
+	return registered, ok
+}
+
+// registerStreamHandler registers the decoder and handler of the messages
+// received for the protocol ID, and sets the host to handle its streams
+// with handleRegisteredStream.
+func (s *Service) registerStreamHandler(protocolID protocol.ID, decoder messageDecoder,
+	handler messageHandler, maxSize uint64) {
+	s.streamHandlers.Register(protocolID, decoder, handler, maxSize)
+	s.host.registerStreamHandler(protocolID, s.handleRegisteredStream)
+}
+
+// handleRegisteredStream reads the messages of the stream, decoding and
+// handling them with the decoder and handler registered for its protocol
+// when the stream is opened.
+func (s *Service) handleRegisteredStream(stream libp2pnetwork.Stream) {
+	if stream == nil {
+		return
+	}
+
+	registered, ok := s.streamHandlers.lookup(stream.Protocol())
+	if !ok {
+		logger.Debugf("%s: %s", errNoHandlerForProtocol, stream.Protocol())
+		s.resetInboundStream(stream)
+		return
+	}
+
+	s.readStream(stream, registered.decoder, registered.handler, registered.maxSize)
+}
@@ -0,0 +1,76 @@
+//go:build integration
+
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_registerStreamHandler(t *testing.T) {
+	t.Parallel()
+
+	configA := &Config{
+		BasePath:    t.TempDir(),
+		Port:        availablePort(t),
+		NoBootstrap: true,
+		NoMDNS:      true,
+	}
+	nodeA := createTestService(t, configA)
+	nodeA.noGossip = true
+
+	const (
+		protocolA = protocol.ID("/test/registry/a")
+		protocolB = protocol.ID("/test/registry/b")
+	)
+
+	received := make(chan protocol.ID, 2)
+	newHandler := func(protocolID protocol.ID) messageHandler {
+		return func(_ libp2pnetwork.Stream, msg Message) error {
+			require.IsType(t, &BlockRequestMessage{}, msg)
+			received <- protocolID
+			return nil
+		}
+	}
+	nodeA.registerStreamHandler(protocolA, decodeSyncMessage, newHandler(protocolA), MaxBlockResponseSize)
+	nodeA.registerStreamHandler(protocolB, decodeSyncMessage, newHandler(protocolB), MaxBlockResponseSize)
+
+	configB := &Config{
+		BasePath:    t.TempDir(),
+		Port:        availablePort(t),
+		NoBootstrap: true,
+		NoMDNS:      true,
+	}
+	nodeB := createTestService(t, configB)
+	nodeB.noGossip = true
+
+	addrInfoA := addrInfo(nodeA.host)
+	err := nodeB.host.connect(addrInfoA)
+	// retry connect if "failed to dial" error
+	if failedToDial(err) {
+		time.Sleep(TestBackoffTimeout)
+		err = nodeB.host.connect(addrInfoA)
+	}
+	require.NoError(t, err)
+
+	testBlockReqMessage := newTestBlockRequestMessage(t)
+
+	for _, protocolID := range []protocol.ID{protocolB, protocolA} {
+		_, err = nodeB.host.send(addrInfoA.ID, protocolID, testBlockReqMessage)
+		require.NoError(t, err)
+
+		select {
+		case receivedProtocolID := <-received:
+			require.Equal(t, protocolID, receivedProtocolID)
+		case <-time.After(TestMessageTimeout):
+			t.Fatalf("timeout waiting for message using protocol %s", protocolID)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package network
+
+import (
+	"testing"
+
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StreamHandlerRegistry_lookup(t *testing.T) {
+	t.Parallel()
+
+	const (
+		protocolA = protocol.ID("/test/a")
+		protocolB = protocol.ID("/test/b")
+		protocolC = protocol.ID("/test/c")
+	)
+
+	received := make(map[protocol.ID][]Message)
+	newHandler := func(protocolID protocol.ID) func(libp2pnetwork.Stream, Message) error {
+		return func(_ libp2pnetwork.Stream, msg Message) error {
+			received[protocolID] = append(received[protocolID], msg)
+			return nil
+		}
+	}
+
+	registry := NewStreamHandlerRegistry()
+	registry.Register(protocolA, decodeSyncMessage, newHandler(protocolA), MaxBlockResponseSize)
+	registry.Register(protocolB, decodeSyncMessage, newHandler(protocolB), MaxBlockResponseSize)
+
+	msgA := &BlockAnnounceHandshake{BestBlockNumber: 1}
+	msgB := &BlockAnnounceHandshake{BestBlockNumber: 2}
+
+	registeredA, ok := registry.lookup(protocolA)
+	require.True(t, ok)
+	err := registeredA.handler(nil, msgA)
+	require.NoError(t, err)
+	registeredB, ok := registry.lookup(protocolB)
+	require.True(t, ok)
+	err = registeredB.handler(nil, msgB)
+	require.NoError(t, err)
+	require.Equal(t, MaxBlockResponseSize, registeredB.maxSize)
+
+	expected := map[protocol.ID][]Message{
+		protocolA: {msgA},
+		protocolB: {msgB},
+	}
+	require.Equal(t, expected, received)
+
+	_, ok = registry.lookup(protocolC)
+	require.False(t, ok)
+}
@@ -130,7 +130,7 @@ func newTestBlockRequestMessage(t *testing.T) *BlockRequestMessage {
 	return &BlockRequestMessage{
 		RequestedData: RequestedDataHeader + RequestedDataBody + RequestedDataJustification,
 		StartingBlock: *starting,
-		Direction:     1,
+		Direction:     Descending,
 		Max:           &one,
 	}
 }
@@ -4,6 +4,9 @@
 package network
 
 import (
+	"time"
+
+	"github.com/ChainSafe/gossamer/dot/peerset"
 	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
 )
 
@@ -19,6 +22,17 @@ func (s *Service) readStream(stream libp2pnetwork.Stream, decoder messageDecoder
 	buffer := s.bufPool.Get().(*[]byte)
 	defer s.bufPool.Put(buffer)
 
+	// the first message, usually the handshake, must be received in time
+	// so peers cannot hold streams open without ever writing to them.
+	waitingFirstMessage := s.cfg.InboundHandshakeTimeout > 0
+	if waitingFirstMessage {
+		err := stream.SetReadDeadline(time.Now().Add(s.cfg.InboundHandshakeTimeout))
+		if err != nil {
+			logger.Tracef("failed to set read deadline on stream id %s: %s", stream.ID(), err)
+			waitingFirstMessage = false
+		}
+	}
+
 	for {
 		n, err := readStream(stream, buffer, maxSize)
 		if err != nil {
@@ -28,6 +42,15 @@ func (s *Service) readStream(stream libp2pnetwork.Stream, decoder messageDecoder
 			return
 		}
 
+		if waitingFirstMessage {
+			waitingFirstMessage = false
+			err = stream.SetReadDeadline(time.Time{})
+			if err != nil {
+				logger.Tracef("failed to clear read deadline on stream id %s: %s", stream.ID(), err)
+				return
+			}
+		}
+
 		s.streamManager.logMessageReceived(stream.ID())
 
 		// decode message based on message type
@@ -37,6 +60,11 @@ func (s *Service) readStream(stream libp2pnetwork.Stream, decoder messageDecoder
 		if err != nil {
 			logger.Tracef("failed to decode message from stream id %s using protocol %s: %s",
 				stream.ID(), stream.Protocol(), err)
+
+			s.host.cm.peerSetHandler.ReportPeer(peerset.ReputationChange{
+				Value:  peerset.BadMessageValue,
+				Reason: peerset.BadMessageReason,
+			}, peer)
 			continue
 		}
 
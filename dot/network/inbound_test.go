@@ -0,0 +1,289 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/gossamer/dot/peerset"
+	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type connWithRemotePeer struct {
+	libp2pnetwork.Conn
+	remotePeer peer.ID
+}
+
+func (c *connWithRemotePeer) RemotePeer() peer.ID { return c.remotePeer }
+
+func Test_Service_readStream_malformedMessage(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	remotePeer := peer.ID("remote")
+
+	// a single message of one byte, followed by the end of the stream
+	streamBuffer := bytes.NewBuffer([]byte{0x01, 0xff})
+	streamMock := NewMockStream(ctrl)
+	streamMock.EXPECT().Conn().Return(&connWithRemotePeer{remotePeer: remotePeer}).AnyTimes()
+	streamMock.EXPECT().ID().Return("stream").AnyTimes()
+	streamMock.EXPECT().Protocol().Return(protocol.ID(blockAnnounceID)).AnyTimes()
+	streamMock.EXPECT().Read(gomock.Any()).DoAndReturn(func(buf []byte) (int, error) {
+		return streamBuffer.Read(buf)
+	}).AnyTimes()
+	streamMock.EXPECT().Stat().Return(libp2pnetwork.Stats{Direction: libp2pnetwork.DirInbound})
+	streamMock.EXPECT().Reset().Return(nil)
+
+	peerSetHandler := NewMockPeerSetHandler(ctrl)
+	peerSetHandler.EXPECT().ReportPeer(peerset.ReputationChange{
+		Value:  peerset.BadMessageValue,
+		Reason: peerset.BadMessageReason,
+	}, remotePeer)
+
+	service := &Service{
+		cfg: &Config{},
+		host: &host{
+			cm: &ConnManager{peerSetHandler: peerSetHandler},
+		},
+		bufPool: &sync.Pool{
+			New: func() interface{} {
+				b := make([]byte, maxMessageSize)
+				return &b
+			},
+		},
+		streamManager: newStreamManager(context.Background()),
+	}
+
+	decoder := func([]byte, peer.ID, bool) (Message, error) {
+		return nil, errors.New("test error")
+	}
+	handler := func(libp2pnetwork.Stream, Message) error {
+		t.Error("handler should not be called")
+		return nil
+	}
+
+	service.readStream(streamMock, decoder, handler, maxMessageSize)
+}
+
+// pipeStream is a libp2p stream backed by one end of a net.Pipe, so its reads
+// block until the other end writes and honour the read deadline set.
+type pipeStream struct {
+	libp2pnetwork.Stream
+	conn       net.Conn
+	remotePeer peer.ID
+	reset      chan struct{}
+}
+
+func newPipeStream(conn net.Conn, remotePeer peer.ID) *pipeStream {
+	return &pipeStream{
+		conn:       conn,
+		remotePeer: remotePeer,
+		reset:      make(chan struct{}),
+	}
+}
+
+func (s *pipeStream) ID() string                         { return "stream" }
+func (s *pipeStream) Protocol() protocol.ID              { return protocol.ID(blockAnnounceID) }
+func (s *pipeStream) Conn() libp2pnetwork.Conn           { return &connWithRemotePeer{remotePeer: s.remotePeer} }
+func (s *pipeStream) Read(b []byte) (int, error)         { return s.conn.Read(b) }
+func (s *pipeStream) SetReadDeadline(t time.Time) error  { return s.conn.SetReadDeadline(t) }
+func (s *pipeStream) SetDeadline(t time.Time) error      { return s.conn.SetDeadline(t) }
+func (s *pipeStream) SetWriteDeadline(t time.Time) error { return s.conn.SetWriteDeadline(t) }
+func (s *pipeStream) Write(b []byte) (int, error)        { return s.conn.Write(b) }
+func (s *pipeStream) Stat() libp2pnetwork.Stats {
+	return libp2pnetwork.Stats{Direction: libp2pnetwork.DirInbound}
+}
+func (s *pipeStream) Reset() error {
+	close(s.reset)
+	return s.conn.Close()
+}
+
+func Test_Service_readStream_handshakeTimeout(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 50 * time.Millisecond
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		_ = remote.Close()
+	})
+	stream := newPipeStream(local, peer.ID("remote"))
+
+	service := &Service{
+		cfg: &Config{InboundHandshakeTimeout: timeout},
+		bufPool: &sync.Pool{
+			New: func() interface{} {
+				b := make([]byte, maxMessageSize)
+				return &b
+			},
+		},
+		streamManager: newStreamManager(context.Background()),
+	}
+
+	decoder := func([]byte, peer.ID, bool) (Message, error) {
+		t.Error("decoder should not be called")
+		return nil, nil //nolint:nilnil
+	}
+	handler := func(libp2pnetwork.Stream, Message) error {
+		t.Error("handler should not be called")
+		return nil
+	}
+
+	// the remote peer opens the stream but never writes to it
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.readStream(stream, decoder, handler, maxMessageSize)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("readStream did not return after the handshake timeout")
+	}
+	require.GreaterOrEqual(t, time.Since(start), timeout)
+
+	select {
+	case <-stream.reset:
+	default:
+		t.Fatal("stream was not reset")
+	}
+
+	// the remote peer sees the stream being torn down
+	_, err := remote.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func Test_Service_readStream_handshakeTimeoutDisabled(t *testing.T) {
+	t.Parallel()
+
+	local, remote := net.Pipe()
+	stream := newPipeStream(local, peer.ID("remote"))
+
+	service := &Service{
+		cfg: &Config{InboundHandshakeTimeout: -1},
+		bufPool: &sync.Pool{
+			New: func() interface{} {
+				b := make([]byte, maxMessageSize)
+				return &b
+			},
+		},
+		streamManager: newStreamManager(context.Background()),
+	}
+
+	decoder := func([]byte, peer.ID, bool) (Message, error) {
+		t.Error("decoder should not be called")
+		return nil, nil //nolint:nilnil
+	}
+	handler := func(libp2pnetwork.Stream, Message) error {
+		t.Error("handler should not be called")
+		return nil
+	}
+
+	// the remote peer opens the stream but never writes to it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.readStream(stream, decoder, handler, maxMessageSize)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("readStream returned without a handshake timeout")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the stream is only torn down once the remote peer closes it
+	require.NoError(t, remote.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("readStream did not return after the stream was closed")
+	}
+}
+
+func Test_Service_readNotificationsStream_duplicateStream(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	remotePeer := peer.ID("remote")
+	conn := &connWithRemotePeer{remotePeer: remotePeer}
+
+	info := newNotificationsProtocol(protocol.ID(blockAnnounceID), nil, nil, nil, maxMessageSize)
+
+	peerSetHandler := NewMockPeerSetHandler(ctrl)
+	service := &Service{
+		cfg: &Config{},
+		host: &host{
+			cm: &ConnManager{peerSetHandler: peerSetHandler},
+		},
+		bufPool: &sync.Pool{
+			New: func() interface{} {
+				b := make([]byte, maxMessageSize)
+				return &b
+			},
+		},
+		streamManager: newStreamManager(context.Background()),
+	}
+
+	decoder := func([]byte, peer.ID, bool) (Message, error) {
+		t.Error("decoder should not be called")
+		return nil, nil //nolint:nilnil
+	}
+	handler := func(libp2pnetwork.Stream, Message) error {
+		t.Error("handler should not be called")
+		return nil
+	}
+
+	// the first stream is being read until the peer closes it
+	firstReading := make(chan struct{})
+	closeFirst := make(chan struct{})
+	firstStream := NewMockStream(ctrl)
+	firstStream.EXPECT().Conn().Return(conn).AnyTimes()
+	firstStream.EXPECT().ID().Return("first").AnyTimes()
+	firstStream.EXPECT().Protocol().Return(info.protocolID).AnyTimes()
+	firstStream.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+		close(firstReading)
+		<-closeFirst
+		return 0, io.EOF
+	})
+	firstStream.EXPECT().Reset().Return(nil)
+
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		service.readNotificationsStream(info, firstStream, decoder, handler, maxMessageSize)
+	}()
+	<-firstReading
+
+	// the second stream of the same peer for the same protocol is reset
+	secondStream := NewMockStream(ctrl)
+	secondStream.EXPECT().Conn().Return(conn).AnyTimes()
+	secondStream.EXPECT().ID().Return("second").AnyTimes()
+	secondStream.EXPECT().Reset().Return(nil)
+	peerSetHandler.EXPECT().ReportPeer(peerset.ReputationChange{
+		Value:  peerset.DuplicateStreamValue,
+		Reason: peerset.DuplicateStreamReason,
+	}, remotePeer)
+
+	service.readNotificationsStream(info, secondStream, decoder, handler, maxMessageSize)
+
+	close(closeFirst)
+	<-firstDone
+
+	// once the first stream is gone, a new stream is accepted
+	require.True(t, info.peersData.addInboundStream(remotePeer, "third"))
+}
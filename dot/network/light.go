@@ -14,11 +14,6 @@
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
-// handleLightStream handles streams with the <protocol-id>/light/2 protocol ID
-func (s *Service) handleLightStream(stream libp2pnetwork.Stream) {
-	s.readStream(stream, s.decodeLightMessage, s.handleLightMsg, MaxBlockResponseSize)
-}
-
 func (s *Service) decodeLightMessage(in []byte, peer peer.ID, _ bool) (Message, error) {
 	s.lightRequestMu.RLock()
 	defer s.lightRequestMu.RUnlock()
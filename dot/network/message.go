@@ -159,6 +159,13 @@ func (bm *BlockRequestMessage) Decode(in []byte) error {
 		return err
 	}
 
+	// the direction is checked before being converted since a byte
+	// conversion would truncate out of range values to valid ones.
+	if msg.Direction != pb.Direction_Ascending && msg.Direction != pb.Direction_Descending {
+		return fmt.Errorf("%w: %d", errBlockRequestDirectionInvalid, msg.Direction)
+	}
+	direction := SyncDirection(msg.Direction)
+
 	if msg.MaxBlocks != 0 {
 		max = &msg.MaxBlocks
 	} else {
@@ -167,7 +174,7 @@ func (bm *BlockRequestMessage) Decode(in []byte) error {
 
 	bm.RequestedData = byte(msg.Fields >> 24)
 	bm.StartingBlock = *startingBlock
-	bm.Direction = SyncDirection(byte(msg.Direction))
+	bm.Direction = direction
 	bm.Max = max
 
 	return nil
@@ -234,6 +241,41 @@ func (bm *BlockResponseMessage) Decode(in []byte) (err error) {
 	return nil
 }
 
+// FilterTo returns a copy of the BlockResponseMessage where the block data
+// fields not set in the requested bitflags are stripped.
+// The block hash is always kept.
+func (bm *BlockResponseMessage) FilterTo(requested byte) *BlockResponseMessage {
+	filtered := &BlockResponseMessage{
+		BlockData: make([]*types.BlockData, len(bm.BlockData)),
+	}
+
+	for i, bd := range bm.BlockData {
+		filteredBlockData := &types.BlockData{
+			Hash: bd.Hash,
+		}
+
+		if requested&RequestedDataHeader != 0 {
+			filteredBlockData.Header = bd.Header
+		}
+		if requested&RequestedDataBody != 0 {
+			filteredBlockData.Body = bd.Body
+		}
+		if requested&RequestedDataReceipt != 0 {
+			filteredBlockData.Receipt = bd.Receipt
+		}
+		if requested&RequestedDataMessageQueue != 0 {
+			filteredBlockData.MessageQueue = bd.MessageQueue
+		}
+		if requested&RequestedDataJustification != 0 {
+			filteredBlockData.Justification = bd.Justification
+		}
+
+		filtered.BlockData[i] = filteredBlockData
+	}
+
+	return filtered
+}
+
 // blockDataToProtobuf converts a gossamer BlockData to a protobuf-defined BlockData
 func blockDataToProtobuf(bd *types.BlockData) (*pb.BlockData, error) {
 	p := &pb.BlockData{
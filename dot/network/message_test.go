@@ -5,12 +5,15 @@
 
 import (
 	"encoding/hex"
+	"fmt"
 	"regexp"
 	"testing"
 
+	pb "github.com/ChainSafe/gossamer/dot/network/proto"
 	"github.com/ChainSafe/gossamer/dot/types"
 	"github.com/ChainSafe/gossamer/lib/common"
 	"github.com/ChainSafe/gossamer/lib/common/variadic"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/stretchr/testify/require"
 )
@@ -26,7 +29,7 @@ func TestEncodeBlockRequestMessage(t *testing.T) {
 	bm := &BlockRequestMessage{
 		RequestedData: 1,
 		StartingBlock: *variadic.NewUint32OrHashFromBytes(append([]byte{0}, genesisHash...)),
-		Direction:     1,
+		Direction:     Descending,
 		Max:           &one,
 	}
 
@@ -50,7 +53,7 @@ func TestEncodeBlockRequestMessage_BlockHash(t *testing.T) {
 	bm := &BlockRequestMessage{
 		RequestedData: 1,
 		StartingBlock: *variadic.NewUint32OrHashFromBytes(append([]byte{0}, genesisHash...)),
-		Direction:     1,
+		Direction:     Descending,
 		Max:           &one,
 	}
 
@@ -70,7 +73,7 @@ func TestEncodeBlockRequestMessage_BlockNumber(t *testing.T) {
 	bm := &BlockRequestMessage{
 		RequestedData: 1,
 		StartingBlock: *variadic.NewUint32OrHashFromBytes([]byte{1, 1}),
-		Direction:     1,
+		Direction:     Descending,
 		Max:           &one,
 	}
 
@@ -91,7 +94,7 @@ func TestBlockRequestString(t *testing.T) {
 	bm := &BlockRequestMessage{
 		RequestedData: 1,
 		StartingBlock: *variadic.NewUint32OrHashFromBytes(append([]byte{0}, genesisHash...)),
-		Direction:     1,
+		Direction:     Descending,
 		Max:           nil,
 	}
 
@@ -110,7 +113,7 @@ func TestEncodeBlockRequestMessage_NoOptionals(t *testing.T) {
 	bm := &BlockRequestMessage{
 		RequestedData: 1,
 		StartingBlock: *variadic.NewUint32OrHashFromBytes(append([]byte{0}, genesisHash...)),
-		Direction:     1,
+		Direction:     Descending,
 		Max:           nil,
 	}
 
@@ -123,6 +126,39 @@ func TestEncodeBlockRequestMessage_NoOptionals(t *testing.T) {
 	require.Equal(t, bm, res)
 }
 
+func TestBlockRequestMessage_Direction(t *testing.T) {
+	t.Parallel()
+
+	for _, direction := range []SyncDirection{Ascending, Descending} {
+		bm := &BlockRequestMessage{
+			RequestedData: BootstrapRequestData,
+			StartingBlock: *variadic.MustNewUint32OrHash(uint32(1)),
+			Direction:     direction,
+		}
+
+		encMsg, err := bm.Encode()
+		require.NoError(t, err)
+
+		res := new(BlockRequestMessage)
+		err = res.Decode(encMsg)
+		require.NoError(t, err)
+		require.Equal(t, bm, res)
+	}
+
+	for _, direction := range []int32{2, 256, 257, -1} {
+		encMsg, err := proto.Marshal(&pb.BlockRequest{
+			Fields:    uint32(BootstrapRequestData) << 24,
+			FromBlock: &pb.BlockRequest_Number{Number: []byte{1, 0, 0, 0}},
+			Direction: pb.Direction(direction),
+		})
+		require.NoError(t, err)
+
+		err = new(BlockRequestMessage).Decode(encMsg)
+		require.ErrorIs(t, err, errBlockRequestDirectionInvalid)
+		require.EqualError(t, err, fmt.Sprintf("block request message Direction is not valid: %d", direction))
+	}
+}
+
 func TestEncodeBlockResponseMessage_Empty(t *testing.T) {
 	t.Parallel()
 
@@ -256,6 +292,68 @@ func TestEncodeBlockResponseMessage_WithAll(t *testing.T) {
 	require.Equal(t, bm, act)
 }
 
+func TestBlockResponseMessage_FilterTo(t *testing.T) {
+	t.Parallel()
+
+	hash := common.Hash{1}
+	header := types.NewHeader(common.Hash{2}, common.Hash{3}, common.Hash{4}, 1, nil)
+	body := types.NewBody(types.BytesArrayToExtrinsics([][]byte{{1, 3, 5, 7}}))
+
+	bm := &BlockResponseMessage{
+		BlockData: []*types.BlockData{{
+			Hash:          hash,
+			Header:        header,
+			Body:          body,
+			Receipt:       &[]byte{1},
+			MessageQueue:  &[]byte{2},
+			Justification: &[]byte{3},
+		}},
+	}
+
+	testCases := map[string]struct {
+		requested byte
+		expected  *types.BlockData
+	}{
+		"nothing": {
+			expected: &types.BlockData{Hash: hash},
+		},
+		"headers_only": {
+			requested: RequestedDataHeader,
+			expected: &types.BlockData{
+				Hash:   hash,
+				Header: header,
+			},
+		},
+		"bootstrap": {
+			requested: BootstrapRequestData,
+			expected: &types.BlockData{
+				Hash:          hash,
+				Header:        header,
+				Body:          body,
+				Justification: &[]byte{3},
+			},
+		},
+		"all": {
+			requested: BootstrapRequestData | RequestedDataReceipt | RequestedDataMessageQueue,
+			expected:  bm.BlockData[0],
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			filtered := bm.FilterTo(testCase.requested)
+			require.Equal(t, []*types.BlockData{testCase.expected}, filtered.BlockData)
+		})
+	}
+
+	// the original message is left untouched
+	require.NotNil(t, bm.BlockData[0].Body)
+	require.NotNil(t, bm.BlockData[0].Justification)
+}
+
 func TestEncodeBlockAnnounceMessage(t *testing.T) {
 	/* this value is a concatenation of:
 	 *  ParentHash: Hash: 0x4545454545454545454545454545454545454545454545454545454545454545
@@ -0,0 +1,167 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ChainSafe/gossamer/dot/network (interfaces: PeerSetHandler)
+//
+// Generated by this command:
+//
+//	mockgen -destination=mock_peer_set_handler_test.go -package network . PeerSetHandler
+//
+
+// Package network is a generated GoMock package.
+package network
+
+import (
+	context "context"
+	reflect "reflect"
+
+	peerset "github.com/ChainSafe/gossamer/dot/peerset"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockPeerSetHandler is a mock of PeerSetHandler interface.
+type MockPeerSetHandler struct {
+	ctrl     *gomock.Controller
+	recorder *MockPeerSetHandlerMockRecorder
+}
+
+// MockPeerSetHandlerMockRecorder is the mock recorder for MockPeerSetHandler.
+type MockPeerSetHandlerMockRecorder struct {
+	mock *MockPeerSetHandler
+}
+
+// NewMockPeerSetHandler creates a new mock instance.
+func NewMockPeerSetHandler(ctrl *gomock.Controller) *MockPeerSetHandler {
+	mock := &MockPeerSetHandler{ctrl: ctrl}
+	mock.recorder = &MockPeerSetHandlerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPeerSetHandler) EXPECT() *MockPeerSetHandlerMockRecorder {
+	return m.recorder
+}
+
+// AddPeer mocks base method.
+func (m *MockPeerSetHandler) AddPeer(arg0 int, arg1 ...peer.ID) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "AddPeer", varargs...)
+}
+
+// AddPeer indicates an expected call of AddPeer.
+func (mr *MockPeerSetHandlerMockRecorder) AddPeer(arg0 any, arg1 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPeer", reflect.TypeOf((*MockPeerSetHandler)(nil).AddPeer), varargs...)
+}
+
+// AddReservedPeer mocks base method.
+func (m *MockPeerSetHandler) AddReservedPeer(arg0 int, arg1 ...peer.ID) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "AddReservedPeer", varargs...)
+}
+
+// AddReservedPeer indicates an expected call of AddReservedPeer.
+func (mr *MockPeerSetHandlerMockRecorder) AddReservedPeer(arg0 any, arg1 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReservedPeer", reflect.TypeOf((*MockPeerSetHandler)(nil).AddReservedPeer), varargs...)
+}
+
+// Incoming mocks base method.
+func (m *MockPeerSetHandler) Incoming(arg0 int, arg1 ...peer.ID) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "Incoming", varargs...)
+}
+
+// Incoming indicates an expected call of Incoming.
+func (mr *MockPeerSetHandlerMockRecorder) Incoming(arg0 any, arg1 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Incoming", reflect.TypeOf((*MockPeerSetHandler)(nil).Incoming), varargs...)
+}
+
+// Messages mocks base method.
+func (m *MockPeerSetHandler) Messages() chan peerset.Message {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Messages")
+	ret0, _ := ret[0].(chan peerset.Message)
+	return ret0
+}
+
+// Messages indicates an expected call of Messages.
+func (mr *MockPeerSetHandlerMockRecorder) Messages() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Messages", reflect.TypeOf((*MockPeerSetHandler)(nil).Messages))
+}
+
+// RemoveReservedPeer mocks base method.
+func (m *MockPeerSetHandler) RemoveReservedPeer(arg0 int, arg1 ...peer.ID) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "RemoveReservedPeer", varargs...)
+}
+
+// RemoveReservedPeer indicates an expected call of RemoveReservedPeer.
+func (mr *MockPeerSetHandlerMockRecorder) RemoveReservedPeer(arg0 any, arg1 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveReservedPeer", reflect.TypeOf((*MockPeerSetHandler)(nil).RemoveReservedPeer), varargs...)
+}
+
+// ReportPeer mocks base method.
+func (m *MockPeerSetHandler) ReportPeer(arg0 peerset.ReputationChange, arg1 ...peer.ID) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "ReportPeer", varargs...)
+}
+
+// ReportPeer indicates an expected call of ReportPeer.
+func (mr *MockPeerSetHandlerMockRecorder) ReportPeer(arg0 any, arg1 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportPeer", reflect.TypeOf((*MockPeerSetHandler)(nil).ReportPeer), varargs...)
+}
+
+// SortedPeers mocks base method.
+func (m *MockPeerSetHandler) SortedPeers(arg0 int) chan peer.IDSlice {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SortedPeers", arg0)
+	ret0, _ := ret[0].(chan peer.IDSlice)
+	return ret0
+}
+
+// SortedPeers indicates an expected call of SortedPeers.
+func (mr *MockPeerSetHandlerMockRecorder) SortedPeers(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SortedPeers", reflect.TypeOf((*MockPeerSetHandler)(nil).SortedPeers), arg0)
+}
+
+// Start mocks base method.
+func (m *MockPeerSetHandler) Start(arg0 context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Start", arg0)
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockPeerSetHandlerMockRecorder) Start(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockPeerSetHandler)(nil).Start), arg0)
+}
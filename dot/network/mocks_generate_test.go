@@ -8,3 +8,4 @@
 //go:generate mockgen -destination=mock_block_state_test.go -package $GOPACKAGE . BlockState
 //go:generate mockgen -destination=mock_transaction_handler_test.go -package $GOPACKAGE . TransactionHandler
 //go:generate mockgen -destination=mock_stream_test.go -package $GOPACKAGE github.com/libp2p/go-libp2p/core/network Stream
+//go:generate mockgen -destination=mock_peer_set_handler_test.go -package $GOPACKAGE . PeerSetHandler
@@ -185,6 +185,29 @@ func (s *Service) createNotificationsMessageHandler(
 	}
 }
 
+// readNotificationsStream reads the inbound stream of a notifications protocol.
+// A peer may only have one inbound stream per notifications protocol, so
+// any additional stream is reset and the peer is reported.
+func (s *Service) readNotificationsStream(info *notificationsProtocol, stream network.Stream,
+	decoder messageDecoder, handler messageHandler, maxSize uint64) {
+	peer := stream.Conn().RemotePeer()
+	if !info.peersData.addInboundStream(peer, stream.ID()) {
+		logger.Debugf("resetting redundant inbound stream id %s of peer %s using protocol %s",
+			stream.ID(), peer, info.protocolID)
+
+		s.host.cm.peerSetHandler.ReportPeer(peerset.ReputationChange{
+			Value:  peerset.DuplicateStreamValue,
+			Reason: peerset.DuplicateStreamReason,
+		}, peer)
+
+		_ = stream.Reset()
+		return
+	}
+	defer info.peersData.deleteInboundStream(peer, stream.ID())
+
+	s.readStream(stream, decoder, handler, maxSize)
+}
+
 func (s *Service) handleHandshake(info *notificationsProtocol, stream network.Stream,
 	hs Handshake, peer peer.ID) error {
 	logger.Tracef("received handshake on notifications sub-protocol %s from peer %s, message is: %s",
@@ -16,6 +16,10 @@ type peersData struct {
 	inbound    map[peer.ID]*handshakeData
 	outboundMu sync.RWMutex
 	outbound   map[peer.ID]*handshakeData
+	// streamsMu protects inboundStreams, the ID of the inbound
+	// stream being read for each peer.
+	streamsMu      sync.Mutex
+	inboundStreams map[peer.ID]string
 }
 
 func newPeersData() *peersData {
@@ -23,6 +27,8 @@ func newPeersData() *peersData {
 		mutexes:  make(map[peer.ID]*sync.Mutex),
 		inbound:  make(map[peer.ID]*handshakeData),
 		outbound: make(map[peer.ID]*handshakeData),
+
+		inboundStreams: make(map[peer.ID]string),
 	}
 }
 
@@ -73,6 +79,27 @@ func (p *peersData) countInboundStreams() (count int64) {
 	return count
 }
 
+// addInboundStream records the stream as the inbound stream of the peer.
+// It returns false if the peer already has another inbound stream.
+func (p *peersData) addInboundStream(peerID peer.ID, streamID string) (added bool) {
+	p.streamsMu.Lock()
+	defer p.streamsMu.Unlock()
+	if _, has := p.inboundStreams[peerID]; has {
+		return false
+	}
+	p.inboundStreams[peerID] = streamID
+	return true
+}
+
+// deleteInboundStream removes the stream if it is the inbound stream of the peer.
+func (p *peersData) deleteInboundStream(peerID peer.ID, streamID string) {
+	p.streamsMu.Lock()
+	defer p.streamsMu.Unlock()
+	if p.inboundStreams[peerID] == streamID {
+		delete(p.inboundStreams, peerID)
+	}
+}
+
 func (p *peersData) getOutboundHandshakeData(peerID peer.ID) (data *handshakeData) {
 	p.outboundMu.RLock()
 	defer p.outboundMu.RUnlock()
@@ -0,0 +1,87 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// maxIdleBuckets is the number of tracked peers above which buckets
+// that are full again get dropped, since they are equivalent to new ones.
+const maxIdleBuckets = 256
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// peerRateLimiter is a per-peer token bucket rate limiter.
+// Each peer starts with a full bucket of `burst` tokens, every allowed
+// request consumes a token and tokens are refilled at `rate` per second.
+type peerRateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[peer.ID]*tokenBucket
+	now     func() time.Time
+}
+
+func newPeerRateLimiter(rate float64, burst uint) *peerRateLimiter {
+	return &peerRateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[peer.ID]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow returns true if the peer has a token available, consuming it.
+func (l *peerRateLimiter) allow(peerID peer.ID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[peerID]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.pruneFullBuckets(now)
+		}
+
+		bucket = &tokenBucket{tokens: l.burst, lastRefill: now}
+		l.buckets[peerID] = bucket
+	}
+
+	l.refill(bucket, now)
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+func (l *peerRateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	bucket.tokens += elapsed * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.lastRefill = now
+}
+
+func (l *peerRateLimiter) pruneFullBuckets(now time.Time) {
+	for peerID, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= l.burst {
+			delete(l.buckets, peerID)
+		}
+	}
+}
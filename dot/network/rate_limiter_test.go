@@ -0,0 +1,64 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_peerRateLimiter_allow(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	limiter := newPeerRateLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	peerA := peer.ID("a")
+	peerB := peer.ID("b")
+
+	// requests within the burst are served
+	for i := 0; i < 3; i++ {
+		require.True(t, limiter.allow(peerA))
+	}
+
+	// excess requests are rejected
+	require.False(t, limiter.allow(peerA))
+
+	// other peers have their own bucket
+	require.True(t, limiter.allow(peerB))
+
+	// the bucket refills at the configured rate
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, limiter.allow(peerA))
+	require.False(t, limiter.allow(peerA))
+
+	// the bucket never refills above the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.True(t, limiter.allow(peerA))
+	}
+	require.False(t, limiter.allow(peerA))
+}
+
+func Test_peerRateLimiter_pruneFullBuckets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	limiter := newPeerRateLimiter(1, 1)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < maxIdleBuckets; i++ {
+		require.True(t, limiter.allow(peer.ID(rune(i))))
+	}
+	require.Len(t, limiter.buckets, maxIdleBuckets)
+
+	// all buckets are full again so they get pruned when a new peer comes in
+	now = now.Add(time.Second)
+	require.True(t, limiter.allow(peer.ID("new")))
+	require.Len(t, limiter.buckets, 1)
+}
@@ -6,10 +6,12 @@
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/ChainSafe/gossamer/dot/peerset"
+	"github.com/ChainSafe/gossamer/pkg/scale"
 	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -85,6 +87,39 @@ func (rrp *RequestResponseProtocol) receiveResponse(stream libp2pnetwork.Stream,
 	return nil
 }
 
+// DoRequest writes the length prefixed encoded request to the stream, then reads
+// the length prefixed response of at most maxRespSize bytes and SCALE decodes it.
+func DoRequest[Req Message, Resp any](stream io.ReadWriter, req Req, maxRespSize uint64) (resp Resp, err error) {
+	encReq, err := req.Encode()
+	if err != nil {
+		return resp, fmt.Errorf("encoding request: %w", err)
+	}
+
+	_, err = stream.Write(append(Uint64ToLEB128(uint64(len(encReq))), encReq...))
+	if err != nil {
+		return resp, fmt.Errorf("writing request: %w", err)
+	}
+
+	// the buffer is grown by readStream to the response size once it is
+	// checked against maxRespSize
+	buf := make([]byte, 0)
+	n, err := readStream(stream, &buf, maxRespSize)
+	if err != nil {
+		return resp, fmt.Errorf("reading response: %w", err)
+	}
+
+	if n == 0 {
+		return resp, ErrReceivedEmptyMessage
+	}
+
+	err = scale.Unmarshal(buf[:n], &resp)
+	if err != nil {
+		return resp, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return resp, nil
+}
+
 type ResponseMessage interface {
 	String() string
 	Encode() ([]byte, error)
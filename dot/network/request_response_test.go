@@ -0,0 +1,100 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package network
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ChainSafe/gossamer/pkg/scale"
+	"github.com/stretchr/testify/require"
+)
+
+type testRequest struct {
+	ID uint32
+}
+
+func (r testRequest) Encode() ([]byte, error) {
+	return scale.Marshal(r)
+}
+
+type testResponse struct {
+	ID   uint32
+	Data []byte
+}
+
+// duplexBuffer is an in-memory stream where reads are served from the
+// response buffer and writes go to the request buffer.
+type duplexBuffer struct {
+	request  *bytes.Buffer
+	response *bytes.Buffer
+}
+
+func (d *duplexBuffer) Read(p []byte) (int, error)  { return d.response.Read(p) }
+func (d *duplexBuffer) Write(p []byte) (int, error) { return d.request.Write(p) }
+
+func newDuplexBuffer(t *testing.T, response any) *duplexBuffer {
+	t.Helper()
+
+	encResponse, err := scale.Marshal(response)
+	require.NoError(t, err)
+
+	return &duplexBuffer{
+		request:  new(bytes.Buffer),
+		response: bytes.NewBuffer(append(Uint64ToLEB128(uint64(len(encResponse))), encResponse...)),
+	}
+}
+
+func Test_DoRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		expectedResponse := testResponse{ID: 1, Data: []byte{1, 2, 3}}
+		stream := newDuplexBuffer(t, expectedResponse)
+
+		response, err := DoRequest[testRequest, testResponse](stream, testRequest{ID: 1}, 1024)
+		require.NoError(t, err)
+		require.Equal(t, expectedResponse, response)
+
+		// the request is written length prefixed
+		expectedRequest := []byte{4, 1, 0, 0, 0}
+		require.Equal(t, expectedRequest, stream.request.Bytes())
+	})
+
+	t.Run("response_too_large", func(t *testing.T) {
+		t.Parallel()
+
+		stream := newDuplexBuffer(t, testResponse{ID: 1, Data: make([]byte, 100)})
+
+		_, err := DoRequest[testRequest, testResponse](stream, testRequest{ID: 1}, 10)
+		require.ErrorIs(t, err, ErrGreaterThanMaxSize)
+	})
+
+	t.Run("empty_response", func(t *testing.T) {
+		t.Parallel()
+
+		stream := &duplexBuffer{
+			request:  new(bytes.Buffer),
+			response: bytes.NewBuffer([]byte{0}),
+		}
+
+		_, err := DoRequest[testRequest, testResponse](stream, testRequest{ID: 1}, 1024)
+		require.ErrorIs(t, err, ErrReceivedEmptyMessage)
+	})
+
+	t.Run("truncated_response", func(t *testing.T) {
+		t.Parallel()
+
+		stream := &duplexBuffer{
+			request:  new(bytes.Buffer),
+			response: bytes.NewBuffer([]byte{8, 1, 0}),
+		}
+
+		_, err := DoRequest[testRequest, testResponse](stream, testRequest{ID: 1}, 1024)
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
@@ -121,9 +121,13 @@ type Service struct {
 	notificationsProtocols map[MessageType]*notificationsProtocol // map of sub-protocol msg ID to protocol info
 	notificationsMu        sync.RWMutex
 
+	streamHandlers *StreamHandlerRegistry // request-response sub-protocol handlers
+
 	lightRequest   map[peer.ID]struct{} // set if we have sent a light request message to the given peer
 	lightRequestMu sync.RWMutex
 
+	blockRequestLimiter *peerRateLimiter
+
 	// Service interfaces
 	blockState         BlockState
 	syncer             Syncer
@@ -180,6 +184,18 @@ func NewService(cfg *Config) (*Service, error) {
 		cfg.batchSize = defaultTxnBatchSize
 	}
 
+	if cfg.BlockRequestRate == 0 {
+		cfg.BlockRequestRate = DefaultBlockRequestRate
+	}
+
+	if cfg.BlockRequestBurst == 0 {
+		cfg.BlockRequestBurst = DefaultBlockRequestBurst
+	}
+
+	if cfg.InboundHandshakeTimeout == 0 {
+		cfg.InboundHandshakeTimeout = DefaultInboundHandshakeTimeout
+	}
+
 	// create a new host instance
 	host, err := newHost(ctx, cfg)
 	if err != nil {
@@ -215,6 +231,7 @@ func NewService(cfg *Config) (*Service, error) {
 		noMDNS:                 cfg.NoMDNS,
 		syncer:                 cfg.Syncer,
 		notificationsProtocols: make(map[MessageType]*notificationsProtocol),
+		streamHandlers:         NewStreamHandlerRegistry(),
 		lightRequest:           make(map[peer.ID]struct{}),
 		telemetryInterval:      cfg.telemetryInterval,
 		closeCh:                make(chan struct{}),
@@ -224,6 +241,10 @@ func NewService(cfg *Config) (*Service, error) {
 		Metrics:                cfg.Metrics,
 	}
 
+	if !cfg.NoBlockRequestRateLimit {
+		network.blockRequestLimiter = newPeerRateLimiter(cfg.BlockRequestRate, cfg.BlockRequestBurst)
+	}
+
 	return network, nil
 }
 
@@ -251,8 +272,8 @@ func (s *Service) Start() error {
 		s.ctx, s.cancel = context.WithCancel(context.Background())
 	}
 
-	s.host.registerStreamHandler(s.host.protocolID+SyncID, s.handleSyncStream)
-	s.host.registerStreamHandler(s.host.protocolID+lightID, s.handleLightStream)
+	s.registerStreamHandler(s.host.protocolID+SyncID, decodeSyncMessage, s.handleSyncMessage, MaxBlockResponseSize)
+	s.registerStreamHandler(s.host.protocolID+lightID, s.decodeLightMessage, s.handleLightMsg, MaxBlockResponseSize)
 
 	// register block announce protocol
 	err := s.RegisterNotificationsProtocol(
@@ -524,7 +545,7 @@ func (s *Service) RegisterNotificationsProtocol(
 
 	s.host.registerStreamHandler(protocolID, func(stream libp2pnetwork.Stream) {
 		logger.Tracef("received stream using sub-protocol %s", protocolID)
-		s.readStream(stream, decoder, handlerWithValidate, maxSize)
+		s.readNotificationsStream(np, stream, decoder, handlerWithValidate, maxSize)
 	})
 
 	logger.Infof("registered notifications sub-protocol %s", protocolID)
@@ -4,19 +4,12 @@
 package network
 
 import (
+	"fmt"
+
 	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
-// handleSyncStream handles streams with the <protocol-id>/sync/2 protocol ID
-func (s *Service) handleSyncStream(stream libp2pnetwork.Stream) {
-	if stream == nil {
-		return
-	}
-
-	s.readStream(stream, decodeSyncMessage, s.handleSyncMessage, MaxBlockResponseSize)
-}
-
 func decodeSyncMessage(in []byte, _ peer.ID, _ bool) (Message, error) {
 	msg := new(BlockRequestMessage)
 	err := msg.Decode(in)
@@ -30,6 +23,21 @@ func (s *Service) handleSyncMessage(stream libp2pnetwork.Stream, msg Message) er
 		return nil
 	}
 
+	req, ok := msg.(*BlockRequestMessage)
+	if !ok {
+		return stream.Close()
+	}
+
+	// requests over the rate limit are refused by resetting the stream rather
+	// than closing it, so the requester knows we are busy and does not mistake
+	// it for an empty response.
+	peerID := stream.Conn().RemotePeer()
+	if s.blockRequestLimiter != nil && !s.blockRequestLimiter.allow(peerID) {
+		logger.Debugf("refusing block request from peer %s: %s", peerID, errBlockRequestRateLimited)
+		_ = stream.Reset()
+		return fmt.Errorf("%w: peer %s", errBlockRequestRateLimited, peerID)
+	}
+
 	defer func() {
 		err := stream.Close()
 		if err != nil && err.Error() != ErrStreamReset.Error() {
@@ -37,17 +45,15 @@ func (s *Service) handleSyncMessage(stream libp2pnetwork.Stream, msg Message) er
 		}
 	}()
 
-	if req, ok := msg.(*BlockRequestMessage); ok {
-		resp, err := s.syncer.CreateBlockResponse(stream.Conn().RemotePeer(), req)
-		if err != nil {
-			logger.Debugf("cannot create response for request: %s", err)
-			return nil
-		}
+	resp, err := s.syncer.CreateBlockResponse(peerID, req)
+	if err != nil {
+		logger.Debugf("cannot create response for request: %s", err)
+		return nil
+	}
 
-		if err = s.host.writeToStream(stream, resp); err != nil {
-			logger.Debugf("failed to send BlockResponse message to peer %s: %s", stream.Conn().RemotePeer(), err)
-			return err
-		}
+	if err = s.host.writeToStream(stream, resp); err != nil {
+		logger.Debugf("failed to send BlockResponse message to peer %s: %s", peerID, err)
+		return err
 	}
 
 	return nil
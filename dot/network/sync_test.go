@@ -4,10 +4,12 @@
 package network
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 func TestDecodeSyncMessage(t *testing.T) {
@@ -25,3 +27,46 @@ func TestDecodeSyncMessage(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, testBlockReqMessage, req)
 }
+
+func Test_Service_handleSyncMessage_rateLimited(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	remotePeer := peer.ID("remote")
+	req := newTestBlockRequestMessage(t)
+
+	newStream := func() *MockStream {
+		stream := NewMockStream(ctrl)
+		stream.EXPECT().Conn().Return(&connWithRemotePeer{remotePeer: remotePeer}).AnyTimes()
+		return stream
+	}
+
+	syncer := NewMockSyncer(ctrl)
+	service := &Service{
+		syncer:              syncer,
+		blockRequestLimiter: newPeerRateLimiter(1, 1),
+	}
+
+	// the first request is served
+	stream := newStream()
+	syncer.EXPECT().CreateBlockResponse(remotePeer, req).Return(nil, errors.New("test error"))
+	stream.EXPECT().Close().Return(nil)
+	err := service.handleSyncMessage(stream, req)
+	require.NoError(t, err)
+
+	// the request over the limit is refused without creating a response
+	stream = newStream()
+	stream.EXPECT().Reset().Return(nil)
+	err = service.handleSyncMessage(stream, req)
+	require.ErrorIs(t, err, errBlockRequestRateLimited)
+
+	// requests are all served once the limiter is disabled
+	service.blockRequestLimiter = nil
+	for i := 0; i < 3; i++ {
+		stream = newStream()
+		syncer.EXPECT().CreateBlockResponse(remotePeer, req).Return(nil, errors.New("test error"))
+		stream.EXPECT().Close().Return(nil)
+		err = service.handleSyncMessage(stream, req)
+		require.NoError(t, err)
+	}
+}
@@ -6,6 +6,7 @@
 import (
 	crand "crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	mrand "math/rand"
@@ -149,41 +150,63 @@ func Uint64ToLEB128(in uint64) []byte {
 	return out
 }
 
+// maxUint64LEB128Size is the maximum number of bytes of a LEB128 encoded uint64.
+const maxUint64LEB128Size = 10
+
+// ReadLEB128ToUint64 reads a LEB128 encoded uint64 from r, and returns it
+// together with the number of bytes read.
 func ReadLEB128ToUint64(r io.Reader) (uint64, int, error) {
-	var out uint64
-	var shift uint
+	counter := &byteCountingReader{reader: r}
+	value, err := readUint64LEB128(counter)
+	return value, counter.bytesRead, err
+}
 
-	maxSize := 10 // Max bytes in LEB128 encoding of uint64 is 10.
-	bytesRead := 0
+// readUint64LEB128 reads a LEB128 encoded uint64 from r one byte at a time.
+// It returns ErrInvalidLEB128EncodedData if the encoding is longer than
+// maxUint64LEB128Size bytes or overflows an uint64, and io.ErrUnexpectedEOF
+// if the encoding is truncated.
+func readUint64LEB128(r io.Reader) (uint64, error) {
+	var out uint64
 
-	for {
-		// read a sinlge byte
+	for i := 0; i < maxUint64LEB128Size; i++ {
 		singleByte := []byte{0}
-		n, err := r.Read(singleByte)
+		_, err := io.ReadFull(r, singleByte)
 		if err != nil {
-			return 0, bytesRead, err
+			if i > 0 && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
 		}
 
-		bytesRead += n
-
 		b := singleByte[0]
-		out |= uint64(0x7F&b) << shift
-		if b&0x80 == 0 {
-			break
+		// the last byte can only hold the most significant bit of the uint64
+		if i == maxUint64LEB128Size-1 && b > 1 {
+			return 0, ErrInvalidLEB128EncodedData
 		}
 
-		maxSize--
-		if maxSize == 0 {
-			return 0, bytesRead, ErrInvalidLEB128EncodedData
+		out |= uint64(0x7F&b) << (7 * i)
+		if b&0x80 == 0 {
+			return out, nil
 		}
-
-		shift += 7
 	}
-	return out, bytesRead, nil
+
+	return 0, ErrInvalidLEB128EncodedData
+}
+
+// byteCountingReader counts the bytes read from its reader.
+type byteCountingReader struct {
+	reader    io.Reader
+	bytesRead int
+}
+
+func (b *byteCountingReader) Read(p []byte) (n int, err error) {
+	n, err = b.reader.Read(p)
+	b.bytesRead += n
+	return n, err
 }
 
 // readStream reads from the stream into the given buffer, returning the number of bytes read
-func readStream(stream libp2pnetwork.Stream, bufPointer *[]byte, maxSize uint64) (tot int, err error) {
+func readStream(stream io.Reader, bufPointer *[]byte, maxSize uint64) (tot int, err error) {
 	if stream == nil {
 		return 0, ErrNilStream
 	}
@@ -197,6 +220,11 @@ func readStream(stream libp2pnetwork.Stream, bufPointer *[]byte, maxSize uint64)
 		return 0, nil // msg length of 0 is allowed, for example transactions handshake
 	}
 
+	if length > maxSize {
+		logger.Warnf("received message with size %d greater than max size %d, closing stream", length, maxSize)
+		return 0, fmt.Errorf("%w: max %d, got %d", ErrGreaterThanMaxSize, maxSize, length)
+	}
+
 	buf := *bufPointer
 	if length > uint64(len(buf)) {
 		logger.Warnf("received message with size %d greater than allocated message buffer size %d", length, len(buf))
@@ -205,11 +233,6 @@ func readStream(stream libp2pnetwork.Stream, bufPointer *[]byte, maxSize uint64)
 		buf = *bufPointer
 	}
 
-	if length > maxSize {
-		logger.Warnf("received message with size %d greater than max size %d, closing stream", length, maxSize)
-		return 0, fmt.Errorf("%w: max %d, got %d", ErrGreaterThanMaxSize, maxSize, length)
-	}
-
 	for tot < int(length) {
 		n, err := stream.Read(buf[tot:])
 		if err != nil {
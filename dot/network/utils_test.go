@@ -5,6 +5,7 @@
 
 import (
 	"bytes"
+	"io"
 	"testing"
 
 	libp2pnetwork "github.com/libp2p/go-libp2p/core/network"
@@ -154,6 +155,66 @@ func TestInvalidLeb128(t *testing.T) {
 	require.Error(t, err)
 }
 
+func Test_readUint64LEB128(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input      []byte
+		output     uint64
+		errWrapped error
+		bytesLeft  int
+	}{
+		"single_byte": {
+			input:  []byte{0x02},
+			output: 2,
+		},
+		"two_bytes": {
+			input:  []byte{0xB9, 0x64},
+			output: 12857,
+		},
+		"max_uint64": {
+			input:  []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01},
+			output: 18446744073709551615,
+		},
+		"trailing_bytes_not_read": {
+			input:     []byte{0x80, 0x01, 0x05},
+			output:    128,
+			bytesLeft: 1,
+		},
+		"overflowing_last_byte": {
+			input:      []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02},
+			errWrapped: ErrInvalidLEB128EncodedData,
+		},
+		"endless_continuation_bytes": {
+			input:      bytes.Repeat([]byte{0x80}, 20),
+			errWrapped: ErrInvalidLEB128EncodedData,
+			bytesLeft:  10,
+		},
+		"empty": {
+			errWrapped: io.EOF,
+		},
+		"truncated": {
+			input:      []byte{0x80, 0x80},
+			errWrapped: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reader := bytes.NewReader(testCase.input)
+
+			output, err := readUint64LEB128(reader)
+
+			require.ErrorIs(t, err, testCase.errWrapped)
+			require.Equal(t, testCase.output, output)
+			require.Equal(t, testCase.bytesLeft, reader.Len())
+		})
+	}
+}
+
 func TestReadStream(t *testing.T) {
 	t.Parallel()
 
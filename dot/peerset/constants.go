@@ -63,6 +63,11 @@
 	// BadJustificationReason is used when peer send invalid justification.
 	BadJustificationReason = "Bad justification"
 
+	// DuplicateStreamValue used when a peer opens a second inbound stream for the same protocol.
+	DuplicateStreamValue Reputation = -(1 << 10)
+	// DuplicateStreamReason used when a peer opens a second inbound stream for the same protocol.
+	DuplicateStreamReason = "Duplicate stream"
+
 	// GenesisMismatch is used when peer has a different genesis
 	GenesisMismatch Reputation = math.MinInt32
 	// GenesisMismatchReason used when a peer has a different genesis
@@ -8,6 +8,7 @@
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/ChainSafe/gossamer/dot/telemetry"
 	"github.com/ChainSafe/gossamer/dot/types"
@@ -150,7 +151,13 @@ func (s *GrandpaState) addScheduledChange(header *types.Header, sc types.Grandpa
 		return fmt.Errorf("cannot import scheduled change: %w", err)
 	}
 
-	logger.Debugf("there are now %d possible scheduled change roots", s.scheduledChangeRoots.Len())
+	tips := s.scheduledChangeRoots.tips()
+	tipsStrings := make([]string, len(tips))
+	for i, tip := range tips {
+		tipsStrings[i] = fmt.Sprintf("%s (#%d)", tip.announcingHeader.Hash().Short(), tip.announcingHeader.Number)
+	}
+	logger.Debugf("there are now %d possible scheduled change roots and %d pending scheduled changes, with tips %s",
+		s.scheduledChangeRoots.Len(), s.scheduledChangeRoots.nodeCount(), strings.Join(tipsStrings, ", "))
 	return nil
 }
 
@@ -164,7 +171,7 @@ func (s *GrandpaState) ApplyScheduledChanges(finalizedHeader *types.Header) erro
 		return fmt.Errorf("cannot prune non-descendant forced changes: %w", err)
 	}
 
-	if s.scheduledChangeRoots.Len() == 0 {
+	if s.scheduledChangeRoots.isEmpty() {
 		return nil
 	}
 
@@ -5,10 +5,12 @@
 
 import (
 	"fmt"
+	"io"
 	"sort"
 
 	"github.com/ChainSafe/gossamer/dot/types"
 	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/ChainSafe/gossamer/pkg/scale"
 )
 
 type conditionFunc[T any] func(T) (bool, error)
@@ -189,6 +191,47 @@ func (c *pendingChangeNode) importNode(blockHash common.Hash, blockNumber uint,
 type changeTree []*pendingChangeNode
 
 func (ct *changeTree) Len() int { return len(*ct) }
+
+// isEmpty returns true if the tree has no pending changes
+func (ct *changeTree) isEmpty() bool { return ct.Len() == 0 }
+
+// nodeCount returns the total number of pending changes
+// across all the forks, while Len only counts the roots
+func (ct *changeTree) nodeCount() int {
+	return changeNodesCount(*ct)
+}
+
+func changeNodesCount(nodes []*pendingChangeNode) (count int) {
+	for _, node := range nodes {
+		count += 1 + changeNodesCount(node.nodes)
+	}
+	return count
+}
+
+// tips returns, for each root, the change at the end of its longest path of
+// descendant changes, the first child being followed on equal lengths.
+// It is used to log the forks of the pending changes.
+func (ct *changeTree) tips() []*pendingChange {
+	tips := make([]*pendingChange, len(*ct))
+	for i, root := range *ct {
+		tips[i], _ = root.deepestChange()
+	}
+	return tips
+}
+
+// deepestChange returns the change at the end of the longest path of
+// descendant changes of the node, and the length of this path.
+func (c *pendingChangeNode) deepestChange() (change *pendingChange, height int) {
+	change = c.change
+	for _, child := range c.nodes {
+		childChange, childHeight := child.deepestChange()
+		if childHeight+1 > height {
+			change, height = childChange, childHeight+1
+		}
+	}
+	return change, height
+}
+
 func (ct *changeTree) importChange(pendingChange *pendingChange, isDescendantOf isDescendantOfFunc) error {
 	for _, root := range *ct {
 		imported, err := root.importNode(pendingChange.announcingHeader.Hash(),
@@ -322,3 +365,88 @@ func (ct *changeTree) pruneChanges(hash common.Hash, isDescendantOf isDescendant
 func (ct *changeTree) pruneAll() {
 	*ct = []*pendingChangeNode{}
 }
+
+// encodedPendingChange is the SCALE encodable form of a pendingChange
+type encodedPendingChange struct {
+	BestFinalizedNumber uint32
+	Delay               uint32
+	NextAuthorities     []types.GrandpaAuthoritiesRaw
+	AnnouncingHeader    types.Header
+}
+
+// encodedChangeNode is the SCALE encodable form of a pendingChangeNode
+type encodedChangeNode struct {
+	Change encodedPendingChange
+	Nodes  []encodedChangeNode
+}
+
+func encodeChangeNodes(nodes []*pendingChangeNode) []encodedChangeNode {
+	encoded := make([]encodedChangeNode, len(nodes))
+	for i, node := range nodes {
+		nextAuthorities := make([]types.GrandpaAuthoritiesRaw, len(node.change.nextAuthorities))
+		for j, authority := range node.change.nextAuthorities {
+			copy(nextAuthorities[j].Key[:], authority.Key.Encode())
+			nextAuthorities[j].ID = authority.Weight
+		}
+
+		encoded[i] = encodedChangeNode{
+			Change: encodedPendingChange{
+				BestFinalizedNumber: node.change.bestFinalizedNumber,
+				Delay:               node.change.delay,
+				NextAuthorities:     nextAuthorities,
+				AnnouncingHeader:    *node.change.announcingHeader,
+			},
+			Nodes: encodeChangeNodes(node.nodes),
+		}
+	}
+	return encoded
+}
+
+func decodeChangeNodes(encoded []encodedChangeNode) ([]*pendingChangeNode, error) {
+	nodes := make([]*pendingChangeNode, len(encoded))
+	for i, encodedNode := range encoded {
+		nextAuthorities, err := types.GrandpaAuthoritiesRawToAuthorities(encodedNode.Change.NextAuthorities)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse next authorities: %w", err)
+		}
+
+		children, err := decodeChangeNodes(encodedNode.Nodes)
+		if err != nil {
+			return nil, err
+		}
+
+		announcingHeader := encodedNode.Change.AnnouncingHeader
+		nodes[i] = &pendingChangeNode{
+			change: &pendingChange{
+				bestFinalizedNumber: encodedNode.Change.BestFinalizedNumber,
+				delay:               encodedNode.Change.Delay,
+				nextAuthorities:     nextAuthorities,
+				announcingHeader:    &announcingHeader,
+			},
+			nodes: children,
+		}
+	}
+	return nodes, nil
+}
+
+// MarshalSCALE encodes the change tree roots together with their children
+func (ct changeTree) MarshalSCALE() ([]byte, error) {
+	return scale.Marshal(encodeChangeNodes(ct))
+}
+
+// UnmarshalSCALE decodes the change tree roots and rebuilds their children
+func (ct *changeTree) UnmarshalSCALE(reader io.Reader) error {
+	var encoded []encodedChangeNode
+	err := scale.NewDecoder(reader).Decode(&encoded)
+	if err != nil {
+		return fmt.Errorf("decoding change nodes: %w", err)
+	}
+
+	nodes, err := decodeChangeNodes(encoded)
+	if err != nil {
+		return err
+	}
+
+	*ct = nodes
+	return nil
+}
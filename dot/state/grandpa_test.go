@@ -4,6 +4,7 @@
 package state
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -15,6 +16,7 @@
 	"github.com/ChainSafe/gossamer/lib/crypto/ed25519"
 	"github.com/ChainSafe/gossamer/lib/crypto/sr25519"
 	"github.com/ChainSafe/gossamer/lib/keystore"
+	"github.com/ChainSafe/gossamer/pkg/scale"
 	inmemory_trie "github.com/ChainSafe/gossamer/pkg/trie/inmemory"
 	"github.com/gtank/merlin"
 	"go.uber.org/mock/gomock"
@@ -143,6 +145,38 @@ func testBlockState(t *testing.T, db database.Database) *BlockState {
 	return bs
 }
 
+func Test_pendingChange_effectiveNumber(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		change          pendingChange
+		effectiveNumber uint
+	}{
+		"zero_delay": {
+			change: pendingChange{
+				announcingHeader: &types.Header{Number: 10},
+			},
+			effectiveNumber: 10,
+		},
+		"non_zero_delay": {
+			change: pendingChange{
+				announcingHeader: &types.Header{Number: 10},
+				delay:            5,
+			},
+			effectiveNumber: 15,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, testCase.effectiveNumber, testCase.change.effectiveNumber())
+		})
+	}
+}
+
 func TestAddScheduledChangesKeepTheRightForkTree(t *testing.T) { //nolint:tparallel
 	t.Parallel()
 
@@ -251,6 +285,471 @@ type headersToAdd struct {
 	}
 }
 
+// depth returns the depth, 0 being a root, of the change node
+// announced by the block with the given hash and whether it was found
+func (ct *changeTree) depth(hash common.Hash) (depth int, found bool) {
+	return changeNodesDepth(*ct, hash, 0)
+}
+
+func changeNodesDepth(nodes []*pendingChangeNode, hash common.Hash, depth int) (int, bool) {
+	for _, node := range nodes {
+		if node.change.announcingHeader.Hash() == hash {
+			return depth, true
+		}
+
+		childDepth, found := changeNodesDepth(node.nodes, hash, depth+1)
+		if found {
+			return childDepth, true
+		}
+	}
+
+	return 0, false
+}
+
+func Test_changeTree_depth(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(number uint, children ...*pendingChangeNode) *pendingChangeNode {
+		return &pendingChangeNode{
+			change: &pendingChange{
+				announcingHeader: &types.Header{Number: number},
+			},
+			nodes: children,
+		}
+	}
+
+	grandChild := newNode(3)
+	child := newNode(2, grandChild)
+	root := newNode(1, child)
+	otherRoot := newNode(4)
+	tree := changeTree{root, otherRoot}
+
+	testCases := map[string]struct {
+		hash  common.Hash
+		depth int
+		found bool
+	}{
+		"root": {
+			hash:  root.change.announcingHeader.Hash(),
+			found: true,
+		},
+		"other_root": {
+			hash:  otherRoot.change.announcingHeader.Hash(),
+			found: true,
+		},
+		"child": {
+			hash:  child.change.announcingHeader.Hash(),
+			depth: 1,
+			found: true,
+		},
+		"grand_child": {
+			hash:  grandChild.change.announcingHeader.Hash(),
+			depth: 2,
+			found: true,
+		},
+		"not_found": {
+			hash: common.Hash{1},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			depth, found := tree.depth(testCase.hash)
+			require.Equal(t, testCase.depth, depth)
+			require.Equal(t, testCase.found, found)
+		})
+	}
+}
+
+func Test_changeTree_nodeCount(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(children ...*pendingChangeNode) *pendingChangeNode {
+		return &pendingChangeNode{nodes: children}
+	}
+
+	testCases := map[string]struct {
+		tree      changeTree
+		nodeCount int
+	}{
+		"empty": {},
+		"single_root": {
+			tree:      changeTree{newNode()},
+			nodeCount: 1,
+		},
+		"multiple_forks": {
+			tree: changeTree{
+				newNode(
+					newNode(newNode(), newNode()),
+					newNode(),
+				),
+				newNode(newNode()),
+			},
+			nodeCount: 7,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, testCase.nodeCount, testCase.tree.nodeCount())
+			require.Equal(t, testCase.nodeCount == 0, testCase.tree.isEmpty())
+		})
+	}
+}
+
+func Test_changeTree_tips(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(number uint, children ...*pendingChangeNode) *pendingChangeNode {
+		return &pendingChangeNode{
+			change: &pendingChange{
+				announcingHeader: &types.Header{Number: number},
+			},
+			nodes: children,
+		}
+	}
+
+	//  1 - 2 - 3
+	//   	//    4 - 5 - 6 - 7
+	//         	//          8
+	deepFork := newNode(7)
+	firstRoot := newNode(1,
+		newNode(2, newNode(3)),
+		newNode(4, newNode(5, newNode(6, deepFork), newNode(8))),
+	)
+
+	//  10 - 11
+	//    	//     12
+	equalForks := newNode(11)
+	secondRoot := newNode(10, equalForks, newNode(12))
+
+	lonelyRoot := newNode(20)
+
+	testCases := map[string]struct {
+		tree changeTree
+		tips []*pendingChange
+	}{
+		"empty": {
+			tips: []*pendingChange{},
+		},
+		"root_without_children": {
+			tree: changeTree{lonelyRoot},
+			tips: []*pendingChange{lonelyRoot.change},
+		},
+		"multiple_levels_and_forks": {
+			tree: changeTree{firstRoot, secondRoot, lonelyRoot},
+			tips: []*pendingChange{deepFork.change, equalForks.change, lonelyRoot.change},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, testCase.tips, testCase.tree.tips())
+		})
+	}
+}
+
+// changeByHash returns the pending change announced by the block
+// with the given hash, or nil if it is not in the tree
+func (ct *changeTree) changeByHash(hash common.Hash) *pendingChange {
+	node := changeNodeByHash(*ct, hash)
+	if node == nil {
+		return nil
+	}
+	return node.change
+}
+
+func changeNodeByHash(nodes []*pendingChangeNode, hash common.Hash) *pendingChangeNode {
+	for _, node := range nodes {
+		if node.change.announcingHeader.Hash() == hash {
+			return node
+		}
+
+		if found := changeNodeByHash(node.nodes, hash); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func Test_changeTree_changeByHash(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(number uint, children ...*pendingChangeNode) *pendingChangeNode {
+		return &pendingChangeNode{
+			change: &pendingChange{
+				announcingHeader: &types.Header{Number: number},
+			},
+			nodes: children,
+		}
+	}
+
+	forkAChild := newNode(3)
+	forkBChild := newNode(4)
+	forkBGrandChild := newNode(5)
+	forkBChild.nodes = []*pendingChangeNode{forkBGrandChild}
+	root := newNode(1, forkAChild, forkBChild)
+	otherRoot := newNode(2)
+	tree := changeTree{root, otherRoot}
+
+	testCases := map[string]struct {
+		hash   common.Hash
+		change *pendingChange
+	}{
+		"root": {
+			hash:   root.change.announcingHeader.Hash(),
+			change: root.change,
+		},
+		"other_root": {
+			hash:   otherRoot.change.announcingHeader.Hash(),
+			change: otherRoot.change,
+		},
+		"first_fork_child": {
+			hash:   forkAChild.change.announcingHeader.Hash(),
+			change: forkAChild.change,
+		},
+		"second_fork_grand_child": {
+			hash:   forkBGrandChild.change.announcingHeader.Hash(),
+			change: forkBGrandChild.change,
+		},
+		"not_found": {
+			hash: common.Hash{1},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			change := tree.changeByHash(testCase.hash)
+			require.Same(t, testCase.change, change)
+		})
+	}
+
+	require.Equal(t, 5, tree.nodeCount())
+}
+
+func Test_changeTree_scaleRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(number uint, parent common.Hash, children ...*pendingChangeNode) *pendingChangeNode {
+		auths, err := types.GrandpaAuthoritiesRawToAuthorities([]types.GrandpaAuthoritiesRaw{
+			{Key: [32]byte{byte(number)}, ID: uint64(number)},
+		})
+		require.NoError(t, err)
+
+		return &pendingChangeNode{
+			change: &pendingChange{
+				bestFinalizedNumber: uint32(number - 1),
+				delay:               uint32(number * 2),
+				nextAuthorities:     auths,
+				announcingHeader:    types.NewHeader(parent, common.Hash{}, common.Hash{}, number, nil),
+			},
+			nodes: children,
+		}
+	}
+
+	// three forks from the first root, the last one being two levels deep
+	tree := changeTree{
+		newNode(1, common.Hash{1},
+			newNode(2, common.Hash{2}),
+			newNode(2, common.Hash{3}),
+			newNode(3, common.Hash{4},
+				newNode(4, common.Hash{5}),
+			),
+		),
+		newNode(5, common.Hash{6}),
+	}
+
+	encoded, err := scale.Marshal(tree)
+	require.NoError(t, err)
+
+	var decoded changeTree
+	err = scale.Unmarshal(encoded, &decoded)
+	require.NoError(t, err)
+
+	var assertNodesEqual func(t *testing.T, expected, actual []*pendingChangeNode)
+	assertNodesEqual = func(t *testing.T, expected, actual []*pendingChangeNode) {
+		t.Helper()
+
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			expectedChange, actualChange := expected[i].change, actual[i].change
+			require.Equal(t, expectedChange.announcingHeader.Hash(), actualChange.announcingHeader.Hash())
+			require.Equal(t, expectedChange.bestFinalizedNumber, actualChange.bestFinalizedNumber)
+			require.Equal(t, expectedChange.delay, actualChange.delay)
+			require.Equal(t, expectedChange.nextAuthorities, actualChange.nextAuthorities)
+			assertNodesEqual(t, expected[i].nodes, actual[i].nodes)
+		}
+	}
+
+	assertNodesEqual(t, tree, decoded)
+	require.Equal(t, tree.nodeCount(), decoded.nodeCount())
+
+	reencoded, err := scale.Marshal(decoded)
+	require.NoError(t, err)
+	require.Equal(t, encoded, reencoded)
+}
+
+var errChangeTreeInvariant = errors.New("change tree invariant violated")
+
+// validateInvariants returns an error if a root of the change tree is neither
+// the finalized block nor one of its descendants, which should never be the
+// case once the tree was updated for the finalized block.
+func (ct *changeTree) validateInvariants(finalizedHash common.Hash, isDescendantOf isDescendantOfFunc) error {
+	for _, root := range *ct {
+		rootHash := root.change.announcingHeader.Hash()
+		isDescendant, err := isDescendantOf(finalizedHash, rootHash)
+		if err != nil {
+			return fmt.Errorf("cannot verify ancestry: %w", err)
+		}
+
+		if !isDescendant {
+			return fmt.Errorf("%w: root %s is not descendant of finalized block %s",
+				errChangeTreeInvariant, rootHash, finalizedHash)
+		}
+	}
+	return nil
+}
+
+func Test_changeTree_findApplicable_invariants(t *testing.T) {
+	t.Parallel()
+
+	// headers maps a block hash to its header to resolve the ancestry
+	headers := make(map[common.Hash]*types.Header)
+	newHeader := func(parent *types.Header, number uint, fork byte) *types.Header {
+		header := &types.Header{
+			Number:    number,
+			StateRoot: common.Hash{fork},
+		}
+		if parent != nil {
+			header.ParentHash = parent.Hash()
+		}
+		headers[header.Hash()] = header
+		return header
+	}
+
+	isDescendantOf := func(parent, child common.Hash) (bool, error) {
+		for hash := child; ; {
+			if hash == parent {
+				return true, nil
+			}
+			header, has := headers[hash]
+			if !has {
+				return false, nil
+			}
+			hash = header.ParentHash
+		}
+	}
+
+	newNode := func(header *types.Header, children ...*pendingChangeNode) *pendingChangeNode {
+		return &pendingChangeNode{
+			change: &pendingChange{announcingHeader: header},
+			nodes:  children,
+		}
+	}
+
+	// G - A - B - F - D
+	//      \
+	//       C
+	genesis := newHeader(nil, 0, 0)
+	headerA := newHeader(genesis, 1, 0)
+	headerB := newHeader(headerA, 2, 0)
+	headerF := newHeader(headerB, 3, 0)
+	headerD := newHeader(headerF, 4, 0)
+	headerC := newHeader(headerA, 2, 1)
+
+	testCases := map[string]struct {
+		tree          changeTree
+		finalized     *types.Header
+		expectedRoots []*types.Header
+		errWrapped    error
+	}{
+		"finalize_change_block": {
+			tree:          changeTree{newNode(headerA, newNode(headerB))},
+			finalized:     headerA,
+			expectedRoots: []*types.Header{headerB},
+		},
+		// the children of the applied change replace the roots without being
+		// pruned, so the child on the fork not including F is kept
+		"finalize_descendant_keeps_forked_children": {
+			tree: changeTree{
+				newNode(headerA,
+					newNode(headerC),
+					newNode(headerD),
+				),
+			},
+			finalized:     headerF,
+			expectedRoots: []*types.Header{headerC, headerD},
+			errWrapped:    errChangeTreeInvariant,
+		},
+		"no_applicable_change_prunes_forks": {
+			tree:          changeTree{newNode(headerC), newNode(headerD)},
+			finalized:     headerF,
+			expectedRoots: []*types.Header{headerD},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tree := testCase.tree
+			_, err := tree.findApplicable(testCase.finalized.Hash(),
+				testCase.finalized.Number, isDescendantOf)
+			require.NoError(t, err)
+
+			err = tree.validateInvariants(testCase.finalized.Hash(), isDescendantOf)
+			require.ErrorIs(t, err, testCase.errWrapped)
+
+			roots := make([]*types.Header, len(tree))
+			for i, root := range tree {
+				roots[i] = root.change.announcingHeader
+			}
+			require.Equal(t, testCase.expectedRoots, roots)
+		})
+	}
+
+	t.Run("prune_keeps_child_at_finalized_block", func(t *testing.T) {
+		t.Parallel()
+
+		// the roots right after applying the change announced at A
+		// are its children, one of them announced at the finalized block
+		tree := changeTree{newNode(headerC), newNode(headerF, newNode(headerD))}
+		err := tree.pruneChanges(headerF.Hash(), isDescendantOf)
+		require.NoError(t, err)
+
+		err = tree.validateInvariants(headerF.Hash(), isDescendantOf)
+		require.NoError(t, err)
+
+		roots := make([]*types.Header, len(tree))
+		for i, root := range tree {
+			roots[i] = root.change.announcingHeader
+		}
+		require.Equal(t, []*types.Header{headerF}, roots)
+	})
+
+	t.Run("invariant_violated", func(t *testing.T) {
+		t.Parallel()
+
+		tree := changeTree{newNode(headerC), newNode(headerD)}
+		err := tree.validateInvariants(headerF.Hash(), isDescendantOf)
+		require.ErrorIs(t, err, errChangeTreeInvariant)
+	})
+}
+
 func assertDescendantChildren(t *testing.T, parentHash common.Hash, isDescendantOfFunc isDescendantOfFunc,
 	changes changeTree) {
 	t.Helper()
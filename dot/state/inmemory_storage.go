@@ -58,18 +58,44 @@ func NewStorageState(db database.Database, blockState *BlockState,
 	}, nil
 }
 
+// changedNodesRecorder is the pruning hook collecting the hashes of the trie
+// nodes changed by a block, to store them in the pruner journal.
+type changedNodesRecorder struct {
+	deleted  map[common.Hash]struct{}
+	inserted map[common.Hash]struct{}
+}
+
+func newChangedNodesRecorder() *changedNodesRecorder {
+	return &changedNodesRecorder{
+		deleted:  make(map[common.Hash]struct{}),
+		inserted: make(map[common.Hash]struct{}),
+	}
+}
+
+func (c *changedNodesRecorder) RecordDeleted(hash common.Hash) {
+	c.deleted[hash] = struct{}{}
+}
+
+func (c *changedNodesRecorder) RecordInserted(hash common.Hash) {
+	c.inserted[hash] = struct{}{}
+}
+
 // StoreTrie stores the given trie in the StorageState and writes it to the database
 func (s *InmemoryStorageState) StoreTrie(ts *storage.TrieState, header *types.Header) error {
 	root := ts.MustRoot()
 	s.tries.softSet(root, ts.Trie())
 
 	if header != nil {
-		insertedNodeHashes, deletedNodeHashes, err := ts.GetChangedNodeHashes()
+		changedNodes := newChangedNodesRecorder()
+		ts.SetPruningHook(changedNodes)
+		err := ts.RecordChangedNodes(header.Hash())
+		ts.SetPruningHook(nil)
 		if err != nil {
-			return fmt.Errorf("getting trie changed node hashes for block hash %s: %w", header.Hash(), err)
+			return fmt.Errorf("recording changed nodes for block hash %s: %w", header.Hash(), err)
 		}
 
-		err = s.pruner.StoreJournalRecord(deletedNodeHashes, insertedNodeHashes, header.Hash(), int64(header.Number))
+		err = s.pruner.StoreJournalRecord(changedNodes.deleted, changedNodes.inserted,
+			header.Hash(), int64(header.Number))
 		if err != nil {
 			return fmt.Errorf("storing journal record: %w", err)
 		}
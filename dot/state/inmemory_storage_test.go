@@ -4,6 +4,7 @@
 package state
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
@@ -164,6 +165,50 @@ func TestStorage_StoreTrie_NotSyncing(t *testing.T) {
 	require.Equal(t, 2, storage.blockState.tries.len())
 }
 
+type journalRecordingPruner struct {
+	deleted, inserted map[common.Hash]struct{}
+	blockHash         common.Hash
+	blockNumber       int64
+}
+
+func (p *journalRecordingPruner) StoreJournalRecord(deletedNodeHashes, insertedNodeHashes map[common.Hash]struct{},
+	blockHash common.Hash, blockNumber int64) error {
+	p.deleted, p.inserted = deletedNodeHashes, insertedNodeHashes
+	p.blockHash, p.blockNumber = blockHash, blockNumber
+	return nil
+}
+
+func TestStorage_StoreTrie_journalsChangedNodes(t *testing.T) {
+	storage := newTestStorageState(t)
+	journal := &journalRecordingPruner{}
+	storage.pruner = journal
+
+	ts, err := storage.TrieState(&trie.EmptyHash)
+	require.NoError(t, err)
+	// values are long enough for the nodes not to be inlined
+	require.NoError(t, ts.Put([]byte("key1"), bytes.Repeat([]byte{1}, 40)))
+	require.NoError(t, ts.Put([]byte("key2"), bytes.Repeat([]byte{2}, 40)))
+	err = storage.StoreTrie(ts, &types.Header{Number: 1})
+	require.NoError(t, err)
+
+	root := ts.MustRoot()
+	ts, err = storage.TrieState(&root)
+	require.NoError(t, err)
+	require.NoError(t, ts.Put([]byte("key1"), bytes.Repeat([]byte{3}, 40)))
+	inserted, deleted, err := ts.GetChangedNodeHashes()
+	require.NoError(t, err)
+	require.NotEmpty(t, deleted)
+
+	header := &types.Header{Number: 2}
+	err = storage.StoreTrie(ts, header)
+	require.NoError(t, err)
+
+	require.Equal(t, deleted, journal.deleted)
+	require.Equal(t, inserted, journal.inserted)
+	require.Equal(t, header.Hash(), journal.blockHash)
+	require.Equal(t, int64(2), journal.blockNumber)
+}
+
 func TestGetStorageChildAndGetStorageFromChild(t *testing.T) {
 	// initialise database using data directory
 	basepath := t.TempDir()
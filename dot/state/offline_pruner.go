@@ -200,6 +200,11 @@ func (p *OfflinePruner) Prune() error {
 		}
 	}
 
+	err = inputDBIter.Err()
+	if err != nil {
+		return fmt.Errorf("iterating over input database: %w", err)
+	}
+
 	err = writeBatch.Flush()
 	if err != nil {
 		return fmt.Errorf("flushing write batch: %w", err)
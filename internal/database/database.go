@@ -22,6 +22,8 @@ type Writer interface {
 
 // Iterator iterates over key/value pairs in ascending key order.
 // Must be released after use.
+// Since an iteration stopped by an error cannot be told apart from a
+// completed one, callers must check Err once done iterating.
 type Iterator interface {
 	Valid() bool
 	Next() bool
@@ -30,6 +32,8 @@ type Iterator interface {
 	First() bool
 	Release()
 	SeekGE(key []byte) bool
+	// Err returns the error, if any, that stopped the iteration.
+	Err() error
 	io.Closer
 }
 
@@ -17,3 +17,8 @@ func (pi *pebbleIterator) Release() {
 		logger.Criticalf("while closing iterator: %s", err)
 	}
 }
+
+// Err returns any accumulated error encountered while iterating.
+func (pi *pebbleIterator) Err() error {
+	return pi.Error()
+}
@@ -4,11 +4,15 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"sync/atomic"
 	"testing"
 
 	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/cockroachdb/pebble/vfs/errorfs"
 	"github.com/stretchr/testify/require"
 )
 
@@ -64,6 +68,51 @@ func TestPebbleDBIterator(t *testing.T) {
 	testSeekKeyValueIterator(t, db)
 }
 
+func TestPebbleDBIteratorErr(t *testing.T) {
+	var failReads atomic.Bool
+	errTest := errors.New("test error")
+	injector := errorfs.InjectorFunc(func(op errorfs.Op, _ string) error {
+		if failReads.Load() && op == errorfs.OpFileReadAt {
+			return errTest
+		}
+		return nil
+	})
+
+	// A tiny block cache forces the iterator to read sstables from the file system.
+	cache := pebble.NewCache(0)
+	defer cache.Unref()
+
+	pebbleDB, err := pebble.Open("", &pebble.Options{
+		FS:    errorfs.Wrap(vfs.NewMem(), injector),
+		Cache: cache,
+	})
+	require.NoError(t, err)
+
+	db := &PebbleDB{db: pebbleDB}
+	t.Cleanup(func() {
+		err := db.Close()
+		require.NoError(t, err)
+	})
+
+	testIteratorSetup(t, db)
+	err = pebbleDB.Flush()
+	require.NoError(t, err)
+
+	it, err := db.NewIterator()
+	require.NoError(t, err)
+	defer it.Release()
+
+	failReads.Store(true)
+
+	counter := 0
+	for succ := it.First(); succ; succ = it.Next() {
+		counter++
+	}
+
+	require.Zero(t, counter)
+	require.ErrorIs(t, it.Err(), errTest)
+}
+
 func testPutGetter(t *testing.T, db Database) {
 	tests := testSetup()
 	for _, v := range tests {
@@ -191,6 +240,7 @@ func testNextKeyIterator(t *testing.T, db Database) {
 	// testIteratorSetup creates 5 entries
 	const expected = 5
 	require.Equal(t, expected, counter)
+	require.NoError(t, it.Err())
 }
 
 func testSeekKeyValueIterator(t *testing.T, db Database) {
@@ -0,0 +1,52 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package grandpa
+
+import (
+	"sort"
+
+	"golang.org/x/exp/constraints"
+)
+
+// AuthoritySetChange records that the authority set with id SetID finalised
+// blocks up to and including BlockNumber, after which the next set was enacted.
+type AuthoritySetChange[N constraints.Unsigned] struct {
+	SetID       uint64
+	BlockNumber N
+}
+
+// AuthoritySetChanges is the log of enacted authority set changes, ordered by
+// set id and block number. It is the data a warp sync proof walks to verify
+// finality across authority sets.
+type AuthoritySetChanges[N constraints.Unsigned] []AuthoritySetChange[N]
+
+// Append records that the set with the given id was replaced after
+// finalising the given block number. Changes must be appended in order.
+func (asc *AuthoritySetChanges[N]) Append(setID uint64, blockNumber N) {
+	*asc = append(*asc, AuthoritySetChange[N]{
+		SetID:       setID,
+		BlockNumber: blockNumber,
+	})
+}
+
+// GetSetID returns the id of the authority set which finalised the given
+// block number. It returns false if the block is beyond the last recorded
+// change, meaning it belongs to the current set, or if the log does not
+// reach back far enough to know the set.
+func (asc AuthoritySetChanges[N]) GetSetID(blockNumber N) (setID uint64, ok bool) {
+	idx := sort.Search(len(asc), func(i int) bool {
+		return asc[i].BlockNumber >= blockNumber
+	})
+	if idx == len(asc) {
+		return 0, false
+	}
+
+	// if the log was started after genesis, the blocks before the first
+	// recorded change may belong to earlier sets we know nothing about.
+	if idx == 0 && asc[0].SetID != 0 {
+		return 0, false
+	}
+
+	return asc[idx].SetID, true
+}
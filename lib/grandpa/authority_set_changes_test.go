@@ -0,0 +1,116 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package grandpa
+
+import (
+	"testing"
+
+	"github.com/ChainSafe/gossamer/pkg/scale"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AuthoritySetChanges_GetSetID(t *testing.T) {
+	t.Parallel()
+
+	var changes AuthoritySetChanges[uint32]
+	changes.Append(0, 41)
+	changes.Append(1, 81)
+	changes.Append(2, 121)
+
+	testCases := map[string]struct {
+		changes     AuthoritySetChanges[uint32]
+		blockNumber uint32
+		setID       uint64
+		ok          bool
+	}{
+		"empty_log": {
+			blockNumber: 10,
+		},
+		"genesis": {
+			changes:     changes,
+			blockNumber: 0,
+			setID:       0,
+			ok:          true,
+		},
+		"last_block_of_first_set": {
+			changes:     changes,
+			blockNumber: 41,
+			setID:       0,
+			ok:          true,
+		},
+		"first_block_of_second_set": {
+			changes:     changes,
+			blockNumber: 42,
+			setID:       1,
+			ok:          true,
+		},
+		"inside_third_set": {
+			changes:     changes,
+			blockNumber: 100,
+			setID:       2,
+			ok:          true,
+		},
+		"last_recorded_block": {
+			changes:     changes,
+			blockNumber: 121,
+			setID:       2,
+			ok:          true,
+		},
+		"current_set": {
+			changes:     changes,
+			blockNumber: 122,
+		},
+		"before_pruned_history": {
+			changes: AuthoritySetChanges[uint32]{
+				{SetID: 3, BlockNumber: 50},
+				{SetID: 4, BlockNumber: 70},
+			},
+			blockNumber: 10,
+		},
+		"after_pruned_history": {
+			changes: AuthoritySetChanges[uint32]{
+				{SetID: 3, BlockNumber: 50},
+				{SetID: 4, BlockNumber: 70},
+			},
+			blockNumber: 51,
+			setID:       4,
+			ok:          true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			setID, ok := testCase.changes.GetSetID(testCase.blockNumber)
+			require.Equal(t, testCase.ok, ok)
+			require.Equal(t, testCase.setID, setID)
+		})
+	}
+}
+
+func Test_AuthoritySetChanges_scale(t *testing.T) {
+	t.Parallel()
+
+	changes := AuthoritySetChanges[uint32]{
+		{SetID: 0, BlockNumber: 41},
+		{SetID: 1, BlockNumber: 81},
+	}
+
+	encoded, err := scale.Marshal(changes)
+	require.NoError(t, err)
+
+	expected := []byte{
+		8, // compact length 2
+		0, 0, 0, 0, 0, 0, 0, 0, 41, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0, 81, 0, 0, 0,
+	}
+	require.Equal(t, expected, encoded)
+
+	var decoded AuthoritySetChanges[uint32]
+	err = scale.Unmarshal(encoded, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, changes, decoded)
+}
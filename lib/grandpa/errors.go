@@ -92,4 +92,5 @@
 	errRoundOutOfBounds         = errors.New("round out of bounds")
 	errRoundsMismatch           = errors.New("rounds mismatch")
 	errInvalidEquivocationStage = errors.New("invalid stage for equivocating")
+	errNeighbourSetIDTooFar     = errors.New("neighbour packet set id is too far ahead")
 )
@@ -24,7 +24,6 @@
 	"github.com/ChainSafe/gossamer/pkg/scale"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"golang.org/x/exp/maps"
 )
 
@@ -36,11 +35,6 @@
 	logger = log.NewFromGlobal(log.AddContext("pkg", "grandpa"))
 
 	ErrUnsupportedSubround = errors.New("unsupported subround")
-	roundGauge             = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "gossamer_grandpa",
-		Name:      "round",
-		Help:      "current grandpa round",
-	})
 )
 
 // Service represents the current state of the grandpa protocol
@@ -80,6 +74,8 @@ type Service struct {
 	finalisedCh chan *types.FinalisationInfo
 
 	telemetry Telemetry
+
+	voterCollector *voterCollector
 }
 
 // Config represents a GRANDPA service configuration
@@ -159,6 +155,7 @@ func NewService(cfg *Config) (*Service, error) {
 
 	s.messageHandler = NewMessageHandler(s, s.blockState, cfg.Telemetry)
 	s.tracker = newTracker(s.blockState, s.messageHandler)
+	s.voterCollector = newVoterCollector(s)
 	s.paused.Store(false)
 	return s, nil
 }
@@ -173,6 +170,11 @@ func (s *Service) Start() error {
 
 	s.tracker.start()
 
+	err := prometheus.Register(s.voterCollector)
+	if err != nil {
+		logger.Warnf("cannot register grandpa voter metrics: %s", err)
+	}
+
 	go func() {
 		err := s.initiate()
 		if err != nil {
@@ -196,6 +198,7 @@ func (s *Service) Stop() error {
 	}
 
 	s.tracker.stop()
+	prometheus.Unregister(s.voterCollector)
 	return nil
 }
 
@@ -232,13 +235,15 @@ func (s *Service) updateAuthorities() error {
 		return fmt.Errorf("cannot get authorities for set id %d: %w", currSetID, err)
 	}
 
+	// the set ID and round are read by GetSetID and GetRound under the round lock
+	s.roundLock.Lock()
 	s.state.voters = nextAuthorities
 	s.state.setID = currSetID
 	// round resets to 1 after a set ID change,
 	// setting to 0 before incrementing indicates
 	// the setID has been increased
 	s.state.round = 0
-	roundGauge.Set(float64(s.state.round))
+	s.roundLock.Unlock()
 
 	s.sendTelemetryAuthoritySet()
 
@@ -288,7 +293,6 @@ func (s *Service) initiateRound() error {
 			"found block finalised in higher round, updating our round to be %d...",
 			round)
 		s.state.round = round
-		roundGauge.Set(float64(s.state.round))
 		err = s.grandpaState.SetLatestRound(round)
 		if err != nil {
 			return err
@@ -297,8 +301,10 @@ func (s *Service) initiateRound() error {
 
 	if setID > s.state.setID {
 		logger.Debugf("found block finalised in higher setID, updating our setID to be %d...", setID)
+		s.roundLock.Lock()
 		s.state.setID = setID
 		s.state.round = round
+		s.roundLock.Unlock()
 	}
 
 	s.head, err = s.blockState.GetFinalisedHeader(round, setID)
@@ -1051,6 +1057,8 @@ func (s *Service) getVotes(stage Subround) []Vote {
 
 // GetSetID returns the current setID
 func (s *Service) GetSetID() uint64 {
+	s.roundLock.Lock()
+	defer s.roundLock.Unlock()
 	return s.state.setID
 }
 
@@ -1102,6 +1110,23 @@ func (s *Service) PreCommits() []ed25519.PublicKeyBytes {
 	return votes
 }
 
+// votesCount returns the number of votes, equivocatory ones included, received
+// for the given stage of the current round.
+func (s *Service) votesCount(stage Subround) int {
+	// the round lock is held so the votes are not reset for a new round meanwhile
+	s.roundLock.Lock()
+	defer s.roundLock.Unlock()
+	s.mapLock.Lock()
+	defer s.mapLock.Unlock()
+
+	switch stage {
+	case precommit:
+		return s.lenVotes(precommit) + len(s.pcEquivocations)
+	default:
+		return s.lenVotes(prevote) + len(s.pvEquivocations)
+	}
+}
+
 func (s *Service) lenVotes(stage Subround) int {
 	var count int
 
@@ -221,6 +221,20 @@ func (m NeighbourPacketV1) String() string {
 	return fmt.Sprintf("NeighbourPacketV1{Round=%d, SetID=%d, Number=%d}", m.Round, m.SetID, m.Number)
 }
 
+// neighbourSetIDTolerance is how many set IDs ahead of ours a peer's neighbour
+// packet may announce before we stop considering the peer for catch up requests.
+const neighbourSetIDTolerance = 1
+
+// Validate checks the neighbour packet against our current set ID, rejecting
+// packets announcing a set ID too far ahead of ours.
+func (m NeighbourPacketV1) Validate(currentSetID uint64) error {
+	if m.SetID > currentSetID+neighbourSetIDTolerance {
+		return fmt.Errorf("%w: got %d, current is %d",
+			errNeighbourSetIDTooFar, m.SetID, currentSetID)
+	}
+	return nil
+}
+
 // ToConsensusMessage converts the NeighbourMessage into a network-level consensus message
 func (m *NeighbourPacketV1) ToConsensusMessage() (*network.ConsensusMessage, error) {
 	versionedNeighbourPacket := VersionedNeighbourPacket{}
@@ -84,6 +84,14 @@ func (h *MessageHandler) handleNeighbourMessage(msg *NeighbourPacketV1) error {
 	// sent by gossamer are being received by substrate nodes
 	// not intended to be production code
 	round, setID := h.blockState.GetRoundAndSetID()
+
+	// ignore neighbour messages from peers too far ahead of our voter set
+	err := msg.Validate(setID)
+	if err != nil {
+		logger.Debugf("ignoring neighbour message: %s", err)
+		return nil
+	}
+
 	neighbourMessage := &NeighbourPacketV1{
 		Round:  round,
 		SetID:  setID,
@@ -0,0 +1,36 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package grandpa
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMessageHandler_handleNeighbourMessage_setIDTooFar(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	blockState := NewMockBlockState(ctrl)
+	blockState.EXPECT().GetRoundAndSetID().Return(uint64(2), uint64(3))
+	// no gossip nor block state lookup is expected for an invalid message
+	network := NewMockNetwork(ctrl)
+
+	h := &MessageHandler{
+		grandpa: &Service{
+			network: network,
+		},
+		blockState: blockState,
+	}
+
+	msg := &NeighbourPacketV1{
+		Round:  2,
+		SetID:  5,
+		Number: 1,
+	}
+	err := h.handleNeighbourMessage(msg)
+	require.NoError(t, err)
+}
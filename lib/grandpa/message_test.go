@@ -0,0 +1,198 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package grandpa
+
+import (
+	"testing"
+
+	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/ChainSafe/gossamer/lib/crypto/ed25519"
+	"github.com/ChainSafe/gossamer/lib/keystore"
+	"github.com/ChainSafe/gossamer/pkg/scale"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeighbourPacketV1_Codec(t *testing.T) {
+	t.Parallel()
+
+	packet := NeighbourPacketV1{
+		Round:  2,
+		SetID:  3,
+		Number: 255,
+	}
+	expected := common.MustHexToBytes("0x02000000000000000300000000000000ff000000")
+
+	encoded, err := scale.Marshal(packet)
+	require.NoError(t, err)
+	require.Equal(t, expected, encoded)
+
+	var decoded NeighbourPacketV1
+	err = scale.Unmarshal(encoded, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, packet, decoded)
+}
+
+func TestNeighbourPacketV1_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		packetSetID  uint64
+		currentSetID uint64
+		errWrapped   error
+		errMessage   string
+	}{
+		"past_set_id": {
+			packetSetID:  1,
+			currentSetID: 3,
+		},
+		"same_set_id": {
+			packetSetID:  3,
+			currentSetID: 3,
+		},
+		"set_id_within_tolerance": {
+			packetSetID:  4,
+			currentSetID: 3,
+		},
+		"set_id_too_far_ahead": {
+			packetSetID:  5,
+			currentSetID: 3,
+			errWrapped:   errNeighbourSetIDTooFar,
+			errMessage:   "neighbour packet set id is too far ahead: got 5, current is 3",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			packet := NeighbourPacketV1{SetID: testCase.packetSetID}
+			err := packet.Validate(testCase.currentSetID)
+			require.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				require.EqualError(t, err, testCase.errMessage)
+			}
+		})
+	}
+}
+
+func Test_decodeMessage_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	hash := common.Hash{1, 2}
+	signedMessage := func(stage Subround) SignedMessage {
+		return SignedMessage{
+			Stage:       stage,
+			BlockHash:   hash,
+			Number:      10,
+			Signature:   [64]byte{3},
+			AuthorityID: ed25519.PublicKeyBytes{4},
+		}
+	}
+	signedVote := SignedVote{
+		Vote:        *NewVote(hash, 10),
+		Signature:   [64]byte{3},
+		AuthorityID: ed25519.PublicKeyBytes{4},
+	}
+
+	testCases := map[string]GrandpaMessage{
+		"prevote": &VoteMessage{
+			Round:   1,
+			SetID:   2,
+			Message: signedMessage(prevote),
+		},
+		"precommit": &VoteMessage{
+			Round:   1,
+			SetID:   2,
+			Message: signedMessage(precommit),
+		},
+		"primary_propose": &VoteMessage{
+			Round:   1,
+			SetID:   2,
+			Message: signedMessage(primaryProposal),
+		},
+		"commit": &CommitMessage{
+			Round:      1,
+			SetID:      2,
+			Vote:       *NewVote(hash, 10),
+			Precommits: []Vote{*NewVote(hash, 10)},
+			AuthData: []AuthData{{
+				Signature:   [64]byte{3},
+				AuthorityID: ed25519.PublicKeyBytes{4},
+			}},
+		},
+		"neighbour": &NeighbourPacketV1{
+			Round:  1,
+			SetID:  2,
+			Number: 10,
+		},
+		"catch_up_request": newCatchUpRequest(1, 2),
+		"catch_up_response": &CatchUpResponse{
+			SetID:                  2,
+			Round:                  1,
+			PreVoteJustification:   []SignedVote{signedVote},
+			PreCommitJustification: []SignedVote{signedVote},
+			Hash:                   hash,
+			Number:                 10,
+		},
+	}
+
+	for name, message := range testCases {
+		message := message
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			consensusMessage, err := message.ToConsensusMessage()
+			require.NoError(t, err)
+
+			decoded, err := decodeMessage(consensusMessage)
+			require.NoError(t, err)
+			require.Equal(t, message, decoded)
+		})
+	}
+}
+
+func Test_validateMessageSignature(t *testing.T) {
+	t.Parallel()
+
+	kr, err := keystore.NewEd25519Keyring()
+	require.NoError(t, err)
+	alice := kr.Alice().(*ed25519.Keypair)
+	bob := kr.Bob().(*ed25519.Keypair)
+
+	vote := NewVote(common.Hash{1}, 10)
+	encodedFullVote, err := scale.Marshal(FullVote{
+		Stage: precommit,
+		Vote:  *vote,
+		Round: 1,
+		SetID: 2,
+	})
+	require.NoError(t, err)
+
+	signature, err := alice.Sign(encodedFullVote)
+	require.NoError(t, err)
+
+	message := &VoteMessage{
+		Round: 1,
+		SetID: 2,
+		Message: SignedMessage{
+			Stage:       precommit,
+			BlockHash:   vote.Hash,
+			Number:      vote.Number,
+			AuthorityID: alice.Public().(*ed25519.PublicKey).AsBytes(),
+		},
+	}
+	copy(message.Message.Signature[:], signature)
+
+	err = validateMessageSignature(alice.Public().(*ed25519.PublicKey), message)
+	require.NoError(t, err)
+
+	err = validateMessageSignature(bob.Public().(*ed25519.PublicKey), message)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// the signature covers the stage of the vote
+	message.Message.Stage = prevote
+	err = validateMessageSignature(alice.Public().(*ed25519.PublicKey), message)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
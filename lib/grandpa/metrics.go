@@ -0,0 +1,62 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package grandpa
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const voterMetricsNamespace = "gossamer_grandpa_voter"
+
+// voterCollector is a prometheus collector exporting the state of the voter
+// each time metrics are gathered.
+type voterCollector struct {
+	service *Service
+
+	round           *prometheus.Desc
+	setID           *prometheus.Desc
+	finalisedNumber *prometheus.Desc
+	prevotes        *prometheus.Desc
+	precommits      *prometheus.Desc
+}
+
+func newVoterCollector(service *Service) *voterCollector {
+	return &voterCollector{
+		service: service,
+		round: prometheus.NewDesc(prometheus.BuildFQName(voterMetricsNamespace, "", "round"),
+			"current grandpa round", nil, nil),
+		setID: prometheus.NewDesc(prometheus.BuildFQName(voterMetricsNamespace, "", "set_id"),
+			"current grandpa voter set id", nil, nil),
+		finalisedNumber: prometheus.NewDesc(prometheus.BuildFQName(voterMetricsNamespace, "", "best_finalised_number"),
+			"number of the highest finalised block", nil, nil),
+		prevotes: prometheus.NewDesc(prometheus.BuildFQName(voterMetricsNamespace, "", "prevotes"),
+			"number of prevotes in the current round", nil, nil),
+		precommits: prometheus.NewDesc(prometheus.BuildFQName(voterMetricsNamespace, "", "precommits"),
+			"number of precommits in the current round", nil, nil),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *voterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.round
+	ch <- c.setID
+	ch <- c.finalisedNumber
+	ch <- c.prevotes
+	ch <- c.precommits
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *voterCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.round, prometheus.GaugeValue, float64(c.service.GetRound()))
+	ch <- prometheus.MustNewConstMetric(c.setID, prometheus.GaugeValue, float64(c.service.GetSetID()))
+	ch <- prometheus.MustNewConstMetric(c.prevotes, prometheus.GaugeValue, float64(c.service.votesCount(prevote)))
+	ch <- prometheus.MustNewConstMetric(c.precommits, prometheus.GaugeValue, float64(c.service.votesCount(precommit)))
+
+	finalised, err := c.service.blockState.GetHighestFinalisedHeader()
+	if err != nil {
+		logger.Debugf("cannot get highest finalised header for metrics: %s", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.finalisedNumber, prometheus.GaugeValue, float64(finalised.Number))
+}
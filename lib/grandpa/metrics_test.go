@@ -0,0 +1,68 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package grandpa
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ChainSafe/gossamer/dot/types"
+	"github.com/ChainSafe/gossamer/lib/crypto/ed25519"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_voterCollector(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	blockState := NewMockBlockState(ctrl)
+	blockState.EXPECT().GetHighestFinalisedHeader().Return(&types.Header{Number: 42}, nil)
+
+	prevotes := new(sync.Map)
+	prevotes.Store(ed25519.PublicKeyBytes{1}, &SignedVote{})
+	prevotes.Store(ed25519.PublicKeyBytes{2}, &SignedVote{})
+	precommits := new(sync.Map)
+	precommits.Store(ed25519.PublicKeyBytes{1}, &SignedVote{})
+
+	service := &Service{
+		blockState: blockState,
+		state:      NewState(nil, 3, 7),
+		prevotes:   prevotes,
+		precommits: precommits,
+		pvEquivocations: map[ed25519.PublicKeyBytes][]*SignedVote{
+			{3}: {{}, {}},
+		},
+		pcEquivocations: map[ed25519.PublicKeyBytes][]*SignedVote{
+			{2}: {{}, {}},
+		},
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	err := registry.Register(newVoterCollector(service))
+	require.NoError(t, err)
+
+	const expected = `
+# HELP gossamer_grandpa_voter_best_finalised_number number of the highest finalised block
+# TYPE gossamer_grandpa_voter_best_finalised_number gauge
+gossamer_grandpa_voter_best_finalised_number 42
+# HELP gossamer_grandpa_voter_precommits number of precommits in the current round
+# TYPE gossamer_grandpa_voter_precommits gauge
+gossamer_grandpa_voter_precommits 2
+# HELP gossamer_grandpa_voter_prevotes number of prevotes in the current round
+# TYPE gossamer_grandpa_voter_prevotes gauge
+gossamer_grandpa_voter_prevotes 3
+# HELP gossamer_grandpa_voter_round current grandpa round
+# TYPE gossamer_grandpa_voter_round gauge
+gossamer_grandpa_voter_round 7
+# HELP gossamer_grandpa_voter_set_id current grandpa voter set id
+# TYPE gossamer_grandpa_voter_set_id gauge
+gossamer_grandpa_voter_set_id 3
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected))
+	require.NoError(t, err)
+}
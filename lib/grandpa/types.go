@@ -122,6 +122,12 @@ type Justification struct {
 	Commit Commit
 }
 
+// Target returns the hash and number of the block the justification
+// finalises.
+func (j *Justification) Target() (common.Hash, uint32) {
+	return j.Commit.Hash, j.Commit.Number
+}
+
 func newJustification(round uint64, hash common.Hash, number uint32, j []SignedVote) *Justification {
 	return &Justification{
 		Round: round,
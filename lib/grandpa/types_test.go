@@ -110,3 +110,36 @@ func TestJustification_Decode(t *testing.T) {
 		fj.Commit.Hash)
 	require.Equal(t, 199, len(fj.Commit.Precommits))
 }
+
+func TestJustification_Codec(t *testing.T) {
+	t.Parallel()
+	// data received from network
+	data := testdata.Data3b1b0(t)
+
+	var justification Justification
+	err := scale.Unmarshal(data, &justification)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6971), justification.Round)
+	require.Equal(t, 199, len(justification.Commit.Precommits))
+
+	enc, err := scale.Marshal(justification)
+	require.NoError(t, err)
+	// the data ends with the empty votes ancestries, which are not decoded
+	require.Equal(t, data[:len(data)-1], enc)
+	require.Equal(t, []byte{0}, data[len(data)-1:])
+}
+
+func TestJustification_Target(t *testing.T) {
+	t.Parallel()
+	justification := Justification{
+		Round: 99,
+		Commit: Commit{
+			Hash:   common.Hash{0xa, 0xb, 0xc, 0xd},
+			Number: 999,
+		},
+	}
+
+	hash, number := justification.Target()
+	require.Equal(t, common.Hash{0xa, 0xb, 0xc, 0xd}, hash)
+	require.Equal(t, uint32(999), number)
+}
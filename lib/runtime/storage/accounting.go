@@ -0,0 +1,73 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package storage
+
+import "sync/atomic"
+
+// storageStats counts the storage operations performed on a TrieState.
+// Its counters are atomic since reads only hold the TrieState read lock.
+type storageStats struct {
+	reads      atomic.Uint64
+	writes     atomic.Uint64
+	readBytes  atomic.Uint64
+	writeBytes atomic.Uint64
+}
+
+func (s *storageStats) recordRead(value []byte) {
+	if s == nil {
+		return
+	}
+	s.reads.Add(1)
+	s.readBytes.Add(uint64(len(value)))
+}
+
+func (s *storageStats) recordWrite(value []byte) {
+	if s == nil {
+		return
+	}
+	s.writes.Add(1)
+	s.writeBytes.Add(uint64(len(value)))
+}
+
+func (s *storageStats) reset() {
+	s.reads.Store(0)
+	s.writes.Store(0)
+	s.readBytes.Store(0)
+	s.writeBytes.Store(0)
+}
+
+// EnableAccounting starts counting the main trie reads done through Get and
+// GetMany, and the writes done through Put and Delete.
+func (t *TrieState) EnableAccounting() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.stats == nil {
+		t.stats = new(storageStats)
+	}
+}
+
+// ResetAccounting sets all the storage operation counters back to zero.
+func (t *TrieState) ResetAccounting() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.stats != nil {
+		t.stats.reset()
+	}
+}
+
+// StorageStats returns the number of reads and writes, and the number of
+// value bytes read and written, since the last call to ResetAccounting.
+// All counters are zero if accounting is not enabled.
+func (t *TrieState) StorageStats() (reads, writes, readBytes, writeBytes uint64) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	if t.stats == nil {
+		return 0, 0, 0, 0
+	}
+	return t.stats.reads.Load(), t.stats.writes.Load(),
+		t.stats.readBytes.Load(), t.stats.writeBytes.Load()
+}
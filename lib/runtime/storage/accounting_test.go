@@ -0,0 +1,53 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package storage
+
+import (
+	"testing"
+
+	inmemory_trie "github.com/ChainSafe/gossamer/pkg/trie/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieState_StorageStats(t *testing.T) {
+	t.Parallel()
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+
+	assertStats := func(t *testing.T, reads, writes, readBytes, writeBytes uint64) {
+		t.Helper()
+		actualReads, actualWrites, actualReadBytes, actualWriteBytes := ts.StorageStats()
+		require.Equal(t, reads, actualReads, "reads")
+		require.Equal(t, writes, actualWrites, "writes")
+		require.Equal(t, readBytes, actualReadBytes, "read bytes")
+		require.Equal(t, writeBytes, actualWriteBytes, "write bytes")
+	}
+
+	// operations are not counted until accounting is enabled
+	require.NoError(t, ts.Put([]byte("key"), []byte("value")))
+	_ = ts.Get([]byte("key"))
+	assertStats(t, 0, 0, 0, 0)
+
+	ts.EnableAccounting()
+
+	require.NoError(t, ts.Put([]byte("key1"), []byte("value1")))
+	require.NoError(t, ts.Put([]byte("key2"), []byte("value22")))
+	// writing the same value again is skipped
+	require.NoError(t, ts.Put([]byte("key2"), []byte("value22")))
+	assertStats(t, 0, 2, 0, 13)
+
+	ts.StartTransaction()
+	_ = ts.Get([]byte("key1"))
+	_ = ts.Get([]byte("missing"))
+	_ = ts.GetMany([][]byte{[]byte("key2"), []byte("key")})
+	require.NoError(t, ts.Delete([]byte("key1")))
+	ts.CommitTransaction()
+	assertStats(t, 4, 3, 18, 13)
+
+	ts.ResetAccounting()
+	assertStats(t, 0, 0, 0, 0)
+
+	_ = ts.Get([]byte("key2"))
+	assertStats(t, 1, 0, 7, 0)
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package storage
+
+import (
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// ReadOnlyTrie is a read only view over the entries of a trie.
+type ReadOnlyTrie interface {
+	Get(key []byte) []byte
+	NextKey(key []byte) []byte
+	Entries() map[string][]byte
+}
+
+// childTrieView is a read only snapshot of a child trie with the changes
+// of a transaction applied on top of it.
+type childTrieView struct {
+	entries    map[string][]byte
+	sortedKeys []string
+}
+
+// newChildTrieView creates a view from the committed child trie entries,
+// which can be nil, and the child trie changes, which can also be nil.
+func newChildTrieView(committed map[string][]byte, changes *storageDiff) *childTrieView {
+	entries := make(map[string][]byte, len(committed))
+	maps.Copy(entries, committed)
+
+	if changes != nil {
+		for k := range changes.deletes {
+			delete(entries, k)
+		}
+		maps.Copy(entries, changes.upserts)
+	}
+
+	sortedKeys := maps.Keys(entries)
+	slices.Sort(sortedKeys)
+
+	return &childTrieView{
+		entries:    entries,
+		sortedKeys: sortedKeys,
+	}
+}
+
+// Get returns the value stored at key, or nil if it does not exist.
+func (v *childTrieView) Get(key []byte) []byte {
+	return v.entries[string(key)]
+}
+
+// NextKey returns the next lexicographical larger key, or nil if it does not exist.
+func (v *childTrieView) NextKey(key []byte) []byte {
+	pos, found := slices.BinarySearch(v.sortedKeys, string(key))
+	if found {
+		pos++
+	}
+
+	if pos >= len(v.sortedKeys) {
+		return nil
+	}
+	return []byte(v.sortedKeys[pos])
+}
+
+// Entries returns a copy of all the key-value pairs of the view.
+func (v *childTrieView) Entries() map[string][]byte {
+	return maps.Clone(v.entries)
+}
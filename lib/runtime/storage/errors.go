@@ -0,0 +1,18 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package storage
+
+import "errors"
+
+var (
+	// ErrTransactionRunning is returned by operations that can only be
+	// performed on the committed state, when a transaction is still open.
+	ErrTransactionRunning = errors.New("transaction running")
+	// ErrProofNotSupported is returned when a proof is requested from a
+	// trie implementation that cannot generate proofs.
+	ErrProofNotSupported = errors.New("proof generation not supported")
+	// ErrSnapshotNotSupported is returned when a snapshot is needed from a
+	// trie implementation that cannot create one.
+	ErrSnapshotNotSupported = errors.New("trie snapshot not supported")
+)
@@ -0,0 +1,111 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ChainSafe/gossamer/lib/common"
+)
+
+// PruningHook is notified of the hashes of the state trie nodes inserted and
+// deleted, so an online pruner can track which nodes are candidates for
+// deletion from disk.
+type PruningHook interface {
+	RecordDeleted(hash common.Hash)
+	RecordInserted(hash common.Hash)
+}
+
+// pruningRecorder notifies a pruning hook of the changed node hashes it was
+// not yet notified of for the block being recorded.
+type pruningRecorder struct {
+	hook      PruningHook
+	blockHash common.Hash
+	deleted   map[common.Hash]struct{}
+	inserted  map[common.Hash]struct{}
+}
+
+// SetPruningHook sets the hook notified of the changed node hashes by
+// RecordChangedNodes. A nil hook disables the notifications.
+func (t *TrieState) SetPruningHook(hook PruningHook) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if hook == nil {
+		t.pruning = nil
+		return
+	}
+
+	t.pruning = &pruningRecorder{
+		hook:     hook,
+		deleted:  make(map[common.Hash]struct{}),
+		inserted: make(map[common.Hash]struct{}),
+	}
+}
+
+// RecordChangedNodes notifies the pruning hook, if any, of the hashes of the
+// nodes inserted and deleted in the trie since the last trie snapshot, which
+// it was not already notified of for the block with the given hash.
+// It should be called once the changes of a block are committed, since a node
+// inserted and then replaced before this call is not reported at all, whereas
+// a node reported as inserted and replaced afterwards is not reported as
+// deleted.
+func (t *TrieState) RecordChangedNodes(blockHash common.Hash) error {
+	hook, deleted, inserted, err := t.newChangedNodes(blockHash)
+	if err != nil {
+		return err
+	}
+
+	// The hook is called without holding the lock, so it can use the
+	// TrieState.
+	for _, hash := range deleted {
+		hook.RecordDeleted(hash)
+	}
+	for _, hash := range inserted {
+		hook.RecordInserted(hash)
+	}
+	return nil
+}
+
+// newChangedNodes returns the pruning hook, if any, with the hashes of the
+// changed nodes it was not yet notified of for the block with the given hash,
+// and marks them as notified.
+func (t *TrieState) newChangedNodes(blockHash common.Hash) (
+	hook PruningHook, deleted, inserted []common.Hash, err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.pruning == nil {
+		return nil, nil, nil, nil
+	}
+
+	insertedHashes, deletedHashes, err := t.state.GetChangedNodeHashes()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("getting changed node hashes: %w", err)
+	}
+
+	if blockHash != t.pruning.blockHash {
+		t.pruning.blockHash = blockHash
+		clear(t.pruning.deleted)
+		clear(t.pruning.inserted)
+	}
+
+	for hash := range deletedHashes {
+		if _, recorded := t.pruning.deleted[hash]; recorded {
+			continue
+		}
+		t.pruning.deleted[hash] = struct{}{}
+		deleted = append(deleted, hash)
+	}
+
+	for hash := range insertedHashes {
+		if _, recorded := t.pruning.inserted[hash]; recorded {
+			continue
+		}
+		t.pruning.inserted[hash] = struct{}{}
+		inserted = append(inserted, hash)
+	}
+
+	return t.pruning.hook, deleted, inserted, nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ChainSafe/gossamer/internal/database"
+	"github.com/ChainSafe/gossamer/lib/common"
+	inmemory_trie "github.com/ChainSafe/gossamer/pkg/trie/inmemory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPruningHook struct {
+	deleted  map[common.Hash]struct{}
+	inserted map[common.Hash]struct{}
+}
+
+func newRecordingPruningHook() *recordingPruningHook {
+	return &recordingPruningHook{
+		deleted:  make(map[common.Hash]struct{}),
+		inserted: make(map[common.Hash]struct{}),
+	}
+}
+
+func (r *recordingPruningHook) RecordDeleted(hash common.Hash) {
+	r.deleted[hash] = struct{}{}
+}
+
+func (r *recordingPruningHook) RecordInserted(hash common.Hash) {
+	r.inserted[hash] = struct{}{}
+}
+
+// rootReadingPruningHook reads the root of the trie state it is set on each
+// time it is notified.
+type rootReadingPruningHook struct {
+	trieState *TrieState
+	root      common.Hash
+}
+
+func (r *rootReadingPruningHook) RecordDeleted(common.Hash) {
+	r.root = r.trieState.MustRoot()
+}
+
+func (r *rootReadingPruningHook) RecordInserted(common.Hash) {
+	r.root = r.trieState.MustRoot()
+}
+
+// newPersistedTrieState returns a trie state backed by a snapshot of a trie
+// written to disk, so changes to it are tracked for the online pruning.
+func newPersistedTrieState(t *testing.T) (ts *TrieState, root common.Hash) {
+	t.Helper()
+
+	db, err := database.NewPebble("", true)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	tr := inmemory_trie.NewEmptyTrie()
+	for _, key := range []string{"key1", "key2", "key3"} {
+		// values are long enough for the nodes not to be inlined
+		require.NoError(t, tr.Put([]byte(key), bytes.Repeat([]byte(key), 10)))
+	}
+	root, err = tr.Hash()
+	require.NoError(t, err)
+
+	snapshot := tr.Snapshot()
+	require.NoError(t, tr.WriteDirty(database.NewTable(db, "storage")))
+
+	return NewTrieState(snapshot), root
+}
+
+func TestTrieState_RecordChangedNodes(t *testing.T) {
+	t.Parallel()
+
+	t.Run("commit", func(t *testing.T) {
+		t.Parallel()
+
+		ts, oldRoot := newPersistedTrieState(t)
+		hook := newRecordingPruningHook()
+		ts.SetPruningHook(hook)
+
+		ts.StartTransaction()
+		require.NoError(t, ts.Put([]byte("key4"), bytes.Repeat([]byte("key4"), 10)))
+		require.NoError(t, ts.Delete([]byte("key1")))
+		ts.StartTransaction()
+		require.NoError(t, ts.Put([]byte("key2"), bytes.Repeat([]byte("new"), 10)))
+		ts.CommitTransaction()
+		ts.CommitTransaction()
+
+		// nothing is recorded until the changed nodes are recorded
+		assert.Empty(t, hook.deleted)
+		assert.Empty(t, hook.inserted)
+
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+
+		inserted, deleted, err := ts.GetChangedNodeHashes()
+		require.NoError(t, err)
+		assert.Equal(t, deleted, hook.deleted)
+		assert.Equal(t, inserted, hook.inserted)
+		assert.Contains(t, hook.deleted, oldRoot)
+		assert.Contains(t, hook.inserted, ts.MustRoot())
+	})
+
+	t.Run("only_new_hashes", func(t *testing.T) {
+		t.Parallel()
+
+		ts, _ := newPersistedTrieState(t)
+		hook := newRecordingPruningHook()
+		ts.SetPruningHook(hook)
+
+		require.NoError(t, ts.Delete([]byte("key1")))
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+		firstDeleted, firstInserted := hook.deleted, hook.inserted
+		require.NotEmpty(t, firstDeleted)
+		require.Contains(t, firstInserted, ts.MustRoot())
+
+		// hashes already recorded are not recorded again
+		hook.deleted = make(map[common.Hash]struct{})
+		hook.inserted = make(map[common.Hash]struct{})
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+		assert.Empty(t, hook.deleted)
+		assert.Empty(t, hook.inserted)
+
+		require.NoError(t, ts.Delete([]byte("key2")))
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+		assert.Contains(t, hook.inserted, ts.MustRoot())
+		for hash := range hook.deleted {
+			assert.NotContains(t, firstDeleted, hash)
+		}
+		for hash := range hook.inserted {
+			assert.NotContains(t, firstInserted, hash)
+		}
+	})
+
+	t.Run("new_block", func(t *testing.T) {
+		t.Parallel()
+
+		ts, _ := newPersistedTrieState(t)
+		hook := newRecordingPruningHook()
+		ts.SetPruningHook(hook)
+
+		require.NoError(t, ts.Delete([]byte("key1")))
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+
+		// hashes recorded for a previous block are recorded again
+		hook.deleted = make(map[common.Hash]struct{})
+		hook.inserted = make(map[common.Hash]struct{})
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{2}))
+
+		inserted, deleted, err := ts.GetChangedNodeHashes()
+		require.NoError(t, err)
+		assert.NotEmpty(t, hook.deleted)
+		assert.Equal(t, deleted, hook.deleted)
+		assert.Equal(t, inserted, hook.inserted)
+	})
+
+	t.Run("hook_using_trie_state", func(t *testing.T) {
+		t.Parallel()
+
+		ts, _ := newPersistedTrieState(t)
+		hook := &rootReadingPruningHook{trieState: ts}
+		ts.SetPruningHook(hook)
+
+		require.NoError(t, ts.Delete([]byte("key1")))
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+		assert.Equal(t, ts.MustRoot(), hook.root)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		t.Parallel()
+
+		ts, _ := newPersistedTrieState(t)
+		hook := newRecordingPruningHook()
+		ts.SetPruningHook(hook)
+
+		ts.StartTransaction()
+		require.NoError(t, ts.Delete([]byte("key1")))
+		ts.RollbackTransaction()
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+
+		assert.Empty(t, hook.deleted)
+		assert.Empty(t, hook.inserted)
+	})
+
+	t.Run("no_hook", func(t *testing.T) {
+		t.Parallel()
+
+		ts, _ := newPersistedTrieState(t)
+		hook := newRecordingPruningHook()
+		ts.SetPruningHook(hook)
+		ts.SetPruningHook(nil)
+
+		require.NoError(t, ts.Delete([]byte("key1")))
+		require.NoError(t, ts.RecordChangedNodes(common.Hash{1}))
+
+		assert.Empty(t, hook.deleted)
+		assert.Empty(t, hook.inserted)
+	})
+}
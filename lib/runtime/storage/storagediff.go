@@ -70,6 +70,20 @@ func (cs *storageDiff) upsert(key string, value []byte) {
 	cs.insertSortedKey(key)
 }
 
+// upsertBatch records the values of the sorted keys, sorting the
+// change set keys once for the whole batch.
+func (cs *storageDiff) upsertBatch(sortedKeys []string, values map[string][]byte) {
+	if cs == nil {
+		return
+	}
+
+	for _, key := range sortedKeys {
+		delete(cs.deletes, key)
+		cs.upserts[key] = values[key]
+	}
+	cs.sortedKeys = mergeSortedKeys(cs.sortedKeys, sortedKeys)
+}
+
 // delete marks a key for deletion and removes it from upserts and
 // child changesets, if present.
 func (cs *storageDiff) delete(key string) {
@@ -142,26 +156,33 @@ func (cs *storageDiff) clearPrefixInChild(keyToChild string, prefix []byte,
 // indicating if all keys with the prefix were removed.
 func (cs *storageDiff) clearPrefix(prefix []byte, trieKeys []string, limit int) (deleted uint32, allDeleted bool) {
 	allKeys := slices.Clone(trieKeys)
-	newKeys := maps.Keys(cs.upserts)
-	allKeys = append(allKeys, newKeys...)
-
-	deleted = 0
+	allKeys = append(allKeys, maps.Keys(cs.upserts)...)
 	sort.Strings(allKeys)
+	// Keys both in the trie and upserted are only considered once
+	allKeys = slices.Compact(allKeys)
+
+	allDeleted = true
 	for _, k := range allKeys {
+		if !bytes.HasPrefix([]byte(k), prefix) || cs.deletes[k] {
+			continue
+		}
+
 		if limit == 0 {
+			allDeleted = false
 			break
 		}
-		keyBytes := []byte(k)
-		if bytes.HasPrefix(keyBytes, prefix) {
-			cs.delete(k)
-			deleted++
-			if !slices.Contains(newKeys, k) {
-				limit--
-			}
+
+		// Only keys which are not upserted during the block execution count
+		// towards the limit
+		_, upserted := cs.upserts[k]
+		cs.delete(k)
+		deleted++
+		if !upserted {
+			limit--
 		}
 	}
 
-	return deleted, deleted == uint32(len(allKeys))
+	return deleted, allDeleted
 }
 
 // getFromChild attempts to retrieve a value associated with a specific key
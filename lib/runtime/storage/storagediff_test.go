@@ -121,28 +121,28 @@ func Test_MainTrie(t *testing.T) {
 				limit:     1,
 				trieKeys:  []string{"bio"},
 				deleted:   3, // Since keys during block exec does not count
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_not_sharing_prefix_limit_2": {
 				prefix:    commonPrefix,
 				limit:     2,
 				trieKeys:  []string{"bio"},
 				deleted:   3, // Since keys during block exec does not count
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_not_sharing_prefix_limit_3": {
 				prefix:    commonPrefix,
 				limit:     3,
 				trieKeys:  []string{"bio"},
 				deleted:   3,
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_not_sharing_prefix_with_no_limit": {
 				prefix:    commonPrefix,
 				limit:     -1,
 				trieKeys:  []string{"bio"},
 				deleted:   3,
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_sharing_prefix_limit_1": {
 				prefix:    []byte("p"),
@@ -172,6 +172,20 @@ func Test_MainTrie(t *testing.T) {
 				deleted:   4,
 				allDelted: true,
 			},
+			"with_previous_state_upserted_keys": {
+				prefix:    commonPrefix,
+				limit:     -1,
+				trieKeys:  []string{"pre", "predict"},
+				deleted:   3, // keys both stored and upserted are counted once
+				allDelted: true,
+			},
+			"with_previous_state_upserted_keys_limit_1": {
+				prefix:    commonPrefix,
+				limit:     1,
+				trieKeys:  []string{"pre", "predict", "prefix"},
+				deleted:   4, // upserted keys do not count towards the limit
+				allDelted: true,
+			},
 		}
 
 		for tname, tt := range cases {
@@ -397,28 +411,28 @@ func Test_ChildTrie(t *testing.T) {
 				limit:     1,
 				trieKeys:  []string{"bio"},
 				deleted:   3, // Since keys during block exec does not count
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_not_sharing_prefix_limit_2": {
 				prefix:    commonPrefix,
 				limit:     2,
 				trieKeys:  []string{"bio"},
 				deleted:   3, // Since keys during block exec does not count
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_not_sharing_prefix_limit_3": {
 				prefix:    commonPrefix,
 				limit:     3,
 				trieKeys:  []string{"bio"},
 				deleted:   3,
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_not_sharing_prefix_with_no_limit": {
 				prefix:    commonPrefix,
 				limit:     -1,
 				trieKeys:  []string{"bio"},
 				deleted:   3,
-				allDelted: false,
+				allDelted: true,
 			},
 			"with_previous_state_sharing_prefix_limit_1": {
 				prefix:    []byte("p"),
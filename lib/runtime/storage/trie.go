@@ -9,14 +9,24 @@
 	"encoding/binary"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ChainSafe/gossamer/lib/common"
 	"github.com/ChainSafe/gossamer/pkg/trie"
+	"github.com/ChainSafe/gossamer/pkg/trie/inmemory"
+	"github.com/ChainSafe/gossamer/pkg/trie/inmemory/proof"
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
+// maxNoopWriteCheckSize is the maximum size of a value for which writes are
+// first compared with the current value, to skip writes not changing it.
+// Larger values are always written since comparing them could cost more
+// than the write itself.
+const maxNoopWriteCheckSize = 256
+
 // TrieState relies on `storageDiff` to perform changes over the current state.
 // It has support for transactions using "nested" storageDiff changes
 // If the execution of the call is successful, the changes will be applied to
@@ -27,6 +37,19 @@ type TrieState struct {
 	transactions    *list.List
 	sortedKeys      []string
 	childSortedKeys map[string][]string
+	// staleChildSortedKeys is nil unless the sorted keys of a child trie
+	// were invalidated, in which case they are not maintained until rebuilt.
+	staleChildSortedKeys map[string]struct{}
+	codeKeyToChild       []byte
+	// changedKeys is nil unless change tracking is enabled.
+	changedKeys map[string]struct{}
+	// transactionDepth mirrors transactions.Len() so readers can
+	// cheaply check whether any transaction is running.
+	transactionDepth atomic.Int32
+	// stats is nil unless accounting is enabled.
+	stats *storageStats
+	// pruning is nil unless a pruning hook is set.
+	pruning *pruningRecorder
 }
 
 // NewTrieState initialises and returns a new TrieState instance
@@ -40,6 +63,14 @@ func NewTrieState(initialState trie.Trie) *TrieState {
 	}
 }
 
+// NewTrieStateWithChangeTracking initialises and returns a new TrieState
+// instance recording the keys modified since the last call to ResetChanges.
+func NewTrieStateWithChangeTracking(initialState trie.Trie) *TrieState {
+	ts := NewTrieState(initialState)
+	ts.changedKeys = make(map[string]struct{})
+	return ts
+}
+
 func (t *TrieState) getCurrentTransaction() *storageDiff {
 	innerTransaction := t.transactions.Back()
 	if innerTransaction == nil {
@@ -66,6 +97,7 @@ func (t *TrieState) StartTransaction() {
 	}
 
 	t.transactions.PushBack(nextChangeSet.snapshot())
+	t.transactionDepth.Add(1)
 }
 
 // RollbackTransaction back all storage changes made since StartTransaction was called.
@@ -78,6 +110,7 @@ func (t *TrieState) RollbackTransaction() {
 	}
 
 	t.transactions.Remove(t.transactions.Back())
+	t.transactionDepth.Add(-1)
 }
 
 // CommitTransaction all storage changes made since StartTransaction was called.
@@ -88,6 +121,7 @@ func (t *TrieState) CommitTransaction() {
 	if t.transactions.Len() == 0 {
 		panic("no transactions to commit")
 	}
+	defer t.transactionDepth.Add(-1)
 
 	if t.transactions.Len() > 1 {
 		// We merge this transaction with its parent transaction
@@ -118,6 +152,30 @@ func (t *TrieState) CommitTransaction() {
 	}
 }
 
+// WithTransaction runs fn inside a new nested storage transaction.
+// The transaction is committed if fn returns nil, and rolled back if fn
+// returns an error or panics, in which case the panic is propagated
+// once the transaction is rolled back.
+func (t *TrieState) WithTransaction(fn func() error) (err error) {
+	t.StartTransaction()
+
+	committed := false
+	defer func() {
+		if !committed {
+			t.RollbackTransaction()
+		}
+	}()
+
+	err = fn()
+	if err != nil {
+		return err
+	}
+
+	t.CommitTransaction()
+	committed = true
+	return nil
+}
+
 // Trie returns the TrieState's underlying trie
 func (t *TrieState) Trie() trie.Trie {
 	t.mtx.RLock()
@@ -126,11 +184,208 @@ func (t *TrieState) Trie() trie.Trie {
 	return t.state
 }
 
+// DeepClone returns a copy of the TrieState sharing no data with it, including
+// its running transactions, so both can be modified concurrently.
+// The backing trie is deep copied since the copy on write mechanism of trie
+// snapshots still mutates the nodes shared between the snapshots.
+// It panics if the backing trie is not an in-memory trie.
+func (t *TrieState) DeepClone() *TrieState {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	inMemoryState, ok := t.state.(*inmemory.InMemoryTrie)
+	if !ok {
+		panic(fmt.Sprintf("cannot deep clone trie of type %T", t.state))
+	}
+
+	transactions := list.New()
+	for e := t.transactions.Front(); e != nil; e = e.Next() {
+		transactions.PushBack(e.Value.(*storageDiff).snapshot())
+	}
+
+	childSortedKeys := make(map[string][]string, len(t.childSortedKeys))
+	for keyToChild, keys := range t.childSortedKeys {
+		childSortedKeys[keyToChild] = slices.Clone(keys)
+	}
+
+	clone := &TrieState{
+		state:                inMemoryState.DeepCopy(),
+		transactions:         transactions,
+		sortedKeys:           slices.Clone(t.sortedKeys),
+		childSortedKeys:      childSortedKeys,
+		staleChildSortedKeys: maps.Clone(t.staleChildSortedKeys),
+		codeKeyToChild:       slices.Clone(t.codeKeyToChild),
+		changedKeys:          maps.Clone(t.changedKeys),
+	}
+	clone.transactionDepth.Store(t.transactionDepth.Load())
+
+	if t.stats != nil {
+		clone.stats = new(storageStats)
+		clone.stats.reads.Store(t.stats.reads.Load())
+		clone.stats.writes.Store(t.stats.writes.Load())
+		clone.stats.readBytes.Store(t.stats.readBytes.Load())
+		clone.stats.writeBytes.Store(t.stats.writeBytes.Load())
+	}
+
+	return clone
+}
+
+// TrieStateSnapshot is the state of a TrieState, including its transaction
+// stack, captured by Snapshot so it can be restored later with Restore.
+type TrieStateSnapshot struct {
+	state                *inmemory.InMemoryTrie
+	transactions         []*storageDiff
+	sortedKeys           []string
+	childSortedKeys      map[string][]string
+	staleChildSortedKeys map[string]struct{}
+	codeKeyToChild       []byte
+	changedKeys          map[string]struct{}
+}
+
+// Snapshot captures the current state of the TrieState, including all its
+// running transactions, so changes made afterwards can be discarded with
+// Restore. Unlike StartTransaction, it can be taken at any transaction depth.
+// The underlying trie is replaced by a copy on write snapshot of itself, so
+// the captured trie is left untouched by later changes. The snapshot keeps
+// the node hashes deleted since the last trie snapshot for online pruning.
+// It panics if the backing trie is not an in-memory trie.
+func (t *TrieState) Snapshot() *TrieStateSnapshot {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	inMemoryState, ok := t.state.(*inmemory.InMemoryTrie)
+	if !ok {
+		panic(fmt.Sprintf("cannot snapshot trie of type %T", t.state))
+	}
+
+	transactions := make([]*storageDiff, 0, t.transactions.Len())
+	for e := t.transactions.Front(); e != nil; e = e.Next() {
+		transactions = append(transactions, e.Value.(*storageDiff).snapshot())
+	}
+
+	childSortedKeys := make(map[string][]string, len(t.childSortedKeys))
+	for keyToChild, keys := range t.childSortedKeys {
+		childSortedKeys[keyToChild] = slices.Clone(keys)
+	}
+
+	t.state = inMemoryState.SnapshotKeepingDeltas()
+
+	return &TrieStateSnapshot{
+		state:                inMemoryState,
+		transactions:         transactions,
+		sortedKeys:           slices.Clone(t.sortedKeys),
+		childSortedKeys:      childSortedKeys,
+		staleChildSortedKeys: maps.Clone(t.staleChildSortedKeys),
+		codeKeyToChild:       slices.Clone(t.codeKeyToChild),
+		changedKeys:          maps.Clone(t.changedKeys),
+	}
+}
+
+// Restore rolls the TrieState back to the given snapshot, discarding all the
+// changes and transactions made since the snapshot was taken.
+// A snapshot can be restored multiple times.
+func (t *TrieState) Restore(snapshot *TrieStateSnapshot) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	transactions := list.New()
+	for _, tx := range snapshot.transactions {
+		transactions.PushBack(tx.snapshot())
+	}
+
+	childSortedKeys := make(map[string][]string, len(snapshot.childSortedKeys))
+	for keyToChild, keys := range snapshot.childSortedKeys {
+		childSortedKeys[keyToChild] = slices.Clone(keys)
+	}
+
+	t.state = snapshot.state.SnapshotKeepingDeltas()
+	t.transactions = transactions
+	t.transactionDepth.Store(int32(transactions.Len()))
+	t.sortedKeys = slices.Clone(snapshot.sortedKeys)
+	t.childSortedKeys = childSortedKeys
+	t.staleChildSortedKeys = maps.Clone(snapshot.staleChildSortedKeys)
+	t.codeKeyToChild = slices.Clone(snapshot.codeKeyToChild)
+	t.changedKeys = maps.Clone(snapshot.changedKeys)
+}
+
+// SwapTrie replaces the underlying trie with newTrie, rebuilding the sorted
+// keys from it, and returns the previous trie.
+// It returns ErrTransactionRunning if a transaction is open.
+func (t *TrieState) SwapTrie(newTrie trie.Trie) (trie.Trie, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.transactions.Len() > 0 {
+		return nil, fmt.Errorf("swapping trie: %w", ErrTransactionRunning)
+	}
+
+	sortedKeys := sortedTrieKeys(newTrie)
+
+	childSortedKeys := make(map[string][]string)
+	for _, childStorageKey := range newTrie.GetKeysWithPrefix(inmemory.ChildStorageKeyPrefix) {
+		keyToChild := childStorageKey[len(inmemory.ChildStorageKeyPrefix):]
+		child, err := newTrie.GetChild(keyToChild)
+		if err != nil {
+			return nil, fmt.Errorf("getting child trie: %w", err)
+		}
+
+		childSortedKeys[string(keyToChild)] = sortedTrieKeys(child)
+	}
+
+	oldTrie := t.state
+	t.state = newTrie
+	t.sortedKeys = sortedKeys
+	t.childSortedKeys = childSortedKeys
+	t.staleChildSortedKeys = nil
+	return oldTrie, nil
+}
+
+// InvalidateChildSortedKeys drops the cached sorted keys of the child trie
+// located at keyToChild, for example when the child trie was rewritten
+// outside of the TrieState. Until RebuildChildSortedKeys is called, the
+// sorted keys are computed from the child trie entries when needed.
+func (t *TrieState) InvalidateChildSortedKeys(keyToChild []byte) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.staleChildSortedKeys == nil {
+		t.staleChildSortedKeys = make(map[string]struct{})
+	}
+	t.staleChildSortedKeys[string(keyToChild)] = struct{}{}
+	delete(t.childSortedKeys, string(keyToChild))
+}
+
+// RebuildChildSortedKeys rebuilds the cached sorted keys of the child trie
+// located at keyToChild from the child trie entries.
+func (t *TrieState) RebuildChildSortedKeys(keyToChild []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	child, err := t.state.GetChild(keyToChild)
+	if err != nil {
+		return fmt.Errorf("getting child trie: %w", err)
+	}
+
+	t.childSortedKeys[string(keyToChild)] = sortedTrieKeys(child)
+	delete(t.staleChildSortedKeys, string(keyToChild))
+	return nil
+}
+
 // Put puts a key-value pair in the trie
 func (t *TrieState) Put(key, value []byte) (err error) {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	if len(value) <= maxNoopWriteCheckSize {
+		current := t.get(key)
+		if current != nil && bytes.Equal(current, value) {
+			return nil
+		}
+	}
+
+	t.trackChange(string(key))
+	t.stats.recordWrite(value)
+
 	// If we have running transactions we apply the change there,
 	// if not, we apply the changes directly on our state trie
 	if t.getCurrentTransaction() != nil {
@@ -146,11 +401,107 @@ func (t *TrieState) Put(key, value []byte) (err error) {
 	return nil
 }
 
+// PutBatch puts all the key-value pairs of entries in the trie, taking the
+// lock once and sorting the new keys once instead of inserting them one by one.
+// Unlike Put, it does not skip writes not changing the current value.
+// If a write to the trie fails, the entries written so far are kept and the
+// sorted keys stay consistent with the trie.
+func (t *TrieState) PutBatch(entries map[string][]byte) (err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	keys := maps.Keys(entries)
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		t.trackChange(key)
+		t.stats.recordWrite(entries[key])
+	}
+
+	// If we have running transactions we apply the changes there,
+	// if not, we apply the changes directly on our state trie
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		currentTx.upsertBatch(keys, entries)
+		return nil
+	}
+
+	written := 0
+	defer func() {
+		t.sortedKeys = mergeSortedKeys(t.sortedKeys, keys[:written])
+	}()
+
+	for _, key := range keys {
+		err = t.state.Put([]byte(key), entries[key])
+		if err != nil {
+			return fmt.Errorf("putting key 0x%x: %w", key, err)
+		}
+		written++
+	}
+
+	return nil
+}
+
 // Get gets a value from the trie
 func (t *TrieState) Get(key []byte) []byte {
 	t.mtx.RLock()
 	defer t.mtx.RUnlock()
 
+	value := t.get(key)
+	t.stats.recordRead(value)
+	return value
+}
+
+// GetWithPresence returns the value stored at key and whether the key is
+// present, distinguishing a key stored with an empty value, for which the
+// value is empty but not nil, from an absent key, for which the value is nil.
+func (t *TrieState) GetWithPresence(key []byte) (value []byte, present bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	value, present = t.getWithPresence(key)
+	t.stats.recordRead(value)
+	return value, present
+}
+
+func (t *TrieState) getWithPresence(key []byte) (value []byte, present bool) {
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		if value, ok := currentTx.upserts[string(key)]; ok {
+			if value == nil {
+				value = []byte{}
+			}
+			return value, true
+		}
+
+		if currentTx.deletes[string(key)] {
+			return nil, false
+		}
+	}
+
+	// The trie returns an empty, non nil, value for keys stored with an empty value
+	value = t.state.Get(key)
+	return value, value != nil
+}
+
+// GetMany gets the values of the given keys from the trie, in the same order
+// as the keys. The value of a missing or deleted key is nil.
+func (t *TrieState) GetMany(keys [][]byte) [][]byte {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = t.get(key)
+		t.stats.recordRead(values[i])
+	}
+	return values
+}
+
+func (t *TrieState) get(key []byte) []byte {
+	// Fast path skipping the transaction lookup if none is running
+	if t.transactionDepth.Load() == 0 {
+		return t.state.Get(key)
+	}
+
 	// If we find the key or it is deleted return from latest transaction
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		val, deleted := currentTx.get(string(key))
@@ -182,6 +533,50 @@ func (t *TrieState) Root() (common.Hash, error) {
 	return t.state.Hash()
 }
 
+// RootWithPendingTransactions returns the root hash the trie would have if the
+// running transactions were all committed. The changes are applied to a
+// snapshot of the trie, so neither the trie nor the transactions are modified.
+func (t *TrieState) RootWithPendingTransactions() (common.Hash, error) {
+	// The write lock is taken since applying the changes to the snapshot
+	// computes Merkle values of the nodes it shares with the trie.
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	currentTx := t.getCurrentTransaction()
+	if currentTx == nil {
+		return t.state.Hash()
+	}
+
+	inMemoryState, ok := t.state.(*inmemory.InMemoryTrie)
+	if !ok {
+		return common.Hash{}, fmt.Errorf("%w: for trie of type %T", ErrSnapshotNotSupported, t.state)
+	}
+
+	// The current transaction already contains the changes of its parents
+	snapshot := inMemoryState.Snapshot()
+	currentTx.applyToTrie(snapshot)
+	return snapshot.Hash()
+}
+
+// PendingChangeCount returns the number of upserts and deletes of the current
+// transaction, as well as the total number of upserts and deletes pending in
+// its child tries. It returns zeros if no transaction is running.
+func (t *TrieState) PendingChangeCount() (upserts, deletes, childChanges int) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	currentTx := t.getCurrentTransaction()
+	if currentTx == nil {
+		return 0, 0, 0
+	}
+
+	for _, childDiff := range currentTx.childChangeSet {
+		childChanges += len(childDiff.upserts) + len(childDiff.deletes)
+	}
+
+	return len(currentTx.upserts), len(currentTx.deletes), childChanges
+}
+
 // Has returns whether or not a key exists
 func (t *TrieState) Has(key []byte) bool {
 	return t.Get(key) != nil
@@ -192,6 +587,9 @@ func (t *TrieState) Delete(key []byte) (err error) {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	t.trackChange(string(key))
+	t.stats.recordWrite(nil)
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		t.getCurrentTransaction().delete(string(key))
 	} else {
@@ -211,33 +609,115 @@ func (t *TrieState) NextKey(key []byte) []byte {
 	defer t.mtx.RUnlock()
 
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
-		mainStateSortedKeys := make([]string, len(t.sortedKeys))
-		copy(mainStateSortedKeys, t.sortedKeys)
-
-		mainStateSortedKeys = slices.DeleteFunc(mainStateSortedKeys, func(s string) bool {
-			_, ok := currentTx.deletes[s]
-			return ok
+		// Both the state and the transaction keys are sorted, so the next key
+		// is the smallest of the next keys found in each of them.
+		mainStateNextKey, mainStateFound := nextSortedKey(t.sortedKeys, string(key), func(k string) bool {
+			return currentTx.deletes[k]
 		})
+		txNextKey, txFound := nextSortedKey(currentTx.sortedKeys, string(key), nil)
+
+		switch {
+		case mainStateFound && (!txFound || mainStateNextKey < txNextKey):
+			return []byte(mainStateNextKey)
+		case txFound:
+			return []byte(txNextKey)
+		default:
+			return nil
+		}
+	}
+
+	return t.state.NextKey(key)
+}
+
+// IterateKeysWithPrefix calls fn for each key starting with the given prefix in
+// lexicographical order, taking into account the changes of the current
+// transaction, until fn returns false. fn must not modify the TrieState.
+func (t *TrieState) IterateKeysWithPrefix(prefix []byte, fn func(key []byte) bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	t.iterateKeysWithPrefix(prefix, fn)
+}
+
+// IterateEntries calls fn for each key-value pair whose key starts with the
+// given prefix in lexicographical order, taking into account the changes of
+// the current transaction, until fn returns false. Unlike TrieEntries, it does
+// not load all the entries in memory. fn must not modify the TrieState.
+func (t *TrieState) IterateEntries(prefix []byte, fn func(key, value []byte) bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
 
-		allSortedKeys := append(mainStateSortedKeys, currentTx.sortedKeys...)
-		sort.Strings(allSortedKeys)
+	t.iterateKeysWithPrefix(prefix, func(key []byte) bool {
+		return fn(key, t.get(key))
+	})
+}
+
+// CountKeysWithPrefix returns the number of keys starting with the given
+// prefix, taking into account the changes of the current transaction.
+func (t *TrieState) CountKeysWithPrefix(prefix []byte) (count uint32) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	t.iterateKeysWithPrefix(prefix, func([]byte) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+func (t *TrieState) iterateKeysWithPrefix(prefix []byte, fn func(key []byte) bool) {
+	var (
+		deletes map[string]bool
+		txKeys  []string
+	)
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		deletes = currentTx.deletes
+		txKeys = currentTx.sortedKeys
+	}
 
-		// Find key position
-		pos, found := slices.BinarySearch(allSortedKeys, string(key))
-		if found {
-			pos += 1
+	stateKey := prefix
+	if t.state.Get(prefix) == nil {
+		stateKey = t.state.NextKey(prefix)
+	}
+	txPos, _ := slices.BinarySearch(txKeys, string(prefix))
+
+	for {
+		stateValid := stateKey != nil && bytes.HasPrefix(stateKey, prefix)
+		txValid := txPos < len(txKeys) && strings.HasPrefix(txKeys[txPos], string(prefix))
+
+		var key []byte
+		switch {
+		case stateValid && txValid:
+			switch cmp := bytes.Compare(stateKey, []byte(txKeys[txPos])); {
+			case cmp < 0:
+				key = stateKey
+				stateKey = t.state.NextKey(stateKey)
+			case cmp > 0:
+				key = []byte(txKeys[txPos])
+				txPos++
+			default:
+				key = stateKey
+				stateKey = t.state.NextKey(stateKey)
+				txPos++
+			}
+		case stateValid:
+			key = stateKey
+			stateKey = t.state.NextKey(stateKey)
+		case txValid:
+			key = []byte(txKeys[txPos])
+			txPos++
+		default:
+			return
 		}
 
-		// Get next key based on that position
-		if pos < len(allSortedKeys) {
-			k := allSortedKeys[pos]
-			return []byte(k)
+		if deletes[string(key)] {
+			continue
 		}
 
-		return nil
+		if !fn(key) {
+			return
+		}
 	}
-
-	return t.state.NextKey(key)
 }
 
 // ClearPrefix deletes all key-value pairs from the trie where the key starts with the given prefix
@@ -245,6 +725,8 @@ func (t *TrieState) ClearPrefix(prefix []byte) error {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	defer t.trackDeletedKeys(t.keysWithPrefix(prefix))
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		trieKeys := t.state.Entries()
 		currentTx.clearPrefix(prefix, maps.Keys(trieKeys), -1)
@@ -255,7 +737,7 @@ func (t *TrieState) ClearPrefix(prefix []byte) error {
 	if err != nil {
 		return err
 	}
-	t.sortedKeys = t.removePrefixedSortedKey(t.sortedKeys, string(prefix), -1)
+	t.sortedKeys = t.removePrefixedSortedKey(t.sortedKeys, string(prefix), nil)
 	return nil
 }
 
@@ -265,6 +747,8 @@ func (t *TrieState) ClearPrefixLimit(prefix []byte, limit uint32) (
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	defer t.trackDeletedKeys(t.keysWithPrefix(prefix))
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		trieKeys := t.state.Entries()
 		deleted, allDeleted = currentTx.clearPrefix(prefix, maps.Keys(trieKeys), int(limit))
@@ -275,7 +759,9 @@ func (t *TrieState) ClearPrefixLimit(prefix []byte, limit uint32) (
 	if err != nil {
 		return 0, false, err
 	}
-	t.sortedKeys = t.removePrefixedSortedKey(t.sortedKeys, string(prefix), int(limit))
+	t.sortedKeys = t.removePrefixedSortedKey(t.sortedKeys, string(prefix), func(key string) bool {
+		return t.state.Get([]byte(key)) == nil
+	})
 	return
 }
 
@@ -284,6 +770,10 @@ func (t *TrieState) TrieEntries() map[string][]byte {
 	t.mtx.RLock()
 	defer t.mtx.RUnlock()
 
+	return t.trieEntries()
+}
+
+func (t *TrieState) trieEntries() map[string][]byte {
 	entries := make(map[string][]byte)
 
 	// Get entries from original trie
@@ -302,12 +792,111 @@ func (t *TrieState) TrieEntries() map[string][]byte {
 	return entries
 }
 
+// Equal returns true if both TrieStates have the same committed state root
+// and, if any of them has running transactions, the same main and child trie
+// entries once the changes of their current transaction are applied.
+func (t *TrieState) Equal(other *TrieState) bool {
+	if t == other {
+		return true
+	}
+
+	// Each side is read under its own lock, one at a time, since holding
+	// both locks could deadlock with a concurrent other.Equal(t) call.
+	root, running, err := t.committedRoot()
+	if err != nil {
+		return false
+	}
+	otherRoot, otherRunning, err := other.committedRoot()
+	if err != nil {
+		return false
+	}
+	if root != otherRoot {
+		return false
+	}
+
+	if !running && !otherRunning {
+		return true
+	}
+
+	if !maps.EqualFunc(t.TrieEntries(), other.TrieEntries(), bytes.Equal) {
+		return false
+	}
+
+	// The committed child tries are the same since the committed roots are,
+	// so only the child tries changed by a transaction are compared.
+	keysToChild := append(t.pendingKeysToChild(), other.pendingKeysToChild()...)
+	return maps.EqualFunc(t.childTriesEntries(keysToChild), other.childTriesEntries(keysToChild),
+		func(entries, otherEntries map[string][]byte) bool {
+			return maps.EqualFunc(entries, otherEntries, bytes.Equal)
+		})
+}
+
+// pendingKeysToChild returns the keys to the child tries changed by the
+// current transaction.
+func (t *TrieState) pendingKeysToChild() []string {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	currentTx := t.getCurrentTransaction()
+	if currentTx == nil {
+		return nil
+	}
+	return maps.Keys(currentTx.childChangeSet)
+}
+
+// childTriesEntries returns the entries of the child tries located at the
+// given keys once the changes of the current transaction are applied, with
+// nil entries for child tries which do not exist.
+func (t *TrieState) childTriesEntries(keysToChild []string) map[string]map[string][]byte {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	childTriesEntries := make(map[string]map[string][]byte, len(keysToChild))
+	for _, keyToChild := range keysToChild {
+		view, err := t.childTrieView([]byte(keyToChild))
+		if err != nil {
+			childTriesEntries[keyToChild] = nil
+			continue
+		}
+		childTriesEntries[keyToChild] = view.entries
+	}
+	return childTriesEntries
+}
+
+// committedRoot returns the root hash of the trie without the changes of the
+// running transactions, and whether any transaction is running.
+func (t *TrieState) committedRoot() (root common.Hash, transactionRunning bool, err error) {
+	// The write lock is taken since hashing the trie caches the Merkle
+	// values of its nodes.
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	root, err = t.state.Hash()
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	return root, t.getCurrentTransaction() != nil, nil
+}
+
 // SetChildStorage sets a key-value pair in a child trie
 func (t *TrieState) SetChildStorage(keyToChild, key, value []byte) error {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
-	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+	// A child trie deleted by the current transaction must be written again
+	// even if its committed state holds the same value.
+	currentTx := t.getCurrentTransaction()
+	childDeleted := currentTx != nil && currentTx.deletes[string(keyToChild)]
+	if !childDeleted && len(value) <= maxNoopWriteCheckSize {
+		current, err := t.getChildStorage(keyToChild, key)
+		if err == nil && current != nil && bytes.Equal(current, value) {
+			return nil
+		}
+	}
+
+	t.trackChildChange(keyToChild)
+
+	if currentTx != nil {
 		keyToChildStr := string(keyToChild)
 		keyString := string(key)
 		currentTx.upsertChild(keyToChildStr, keyString, value)
@@ -334,11 +923,47 @@ func (t *TrieState) GetChildRoot(keyToChild []byte) (common.Hash, error) {
 	return child.Hash()
 }
 
+// GenerateChildProof returns the encoded proof nodes for the given keys
+// within the child trie located at keyToChild. The proof can be verified
+// against the child trie root. It cannot be called with running transactions
+// since the pending changes are not part of the child trie yet.
+func (t *TrieState) GenerateChildProof(keyToChild []byte, keys [][]byte) ([][]byte, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		return nil, fmt.Errorf("generating child proof: %w", ErrTransactionRunning)
+	}
+
+	child, err := t.state.GetChild(keyToChild)
+	if err != nil {
+		return nil, err
+	}
+
+	inMemoryChild, ok := child.(*inmemory.InMemoryTrie)
+	if !ok {
+		return nil, fmt.Errorf("%w: for child trie of type %T", ErrProofNotSupported, child)
+	}
+
+	// Make sure the Merkle values of the child trie nodes are up to date
+	// before encoding them in the proof.
+	_, err = inMemoryChild.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("hashing child trie located at key 0x%x: %w", keyToChild, err)
+	}
+
+	return proof.GenerateFromTrie(inMemoryChild, keys)
+}
+
 // GetChildStorage returns a value from a child trie
 func (t *TrieState) GetChildStorage(keyToChild, key []byte) ([]byte, error) {
 	t.mtx.RLock()
 	defer t.mtx.RUnlock()
 
+	return t.getChildStorage(keyToChild, key)
+}
+
+func (t *TrieState) getChildStorage(keyToChild, key []byte) ([]byte, error) {
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		val, deleted := currentTx.getFromChild(string(keyToChild), string(key))
 		if val != nil || deleted {
@@ -355,6 +980,8 @@ func (t *TrieState) DeleteChild(keyToChild []byte) error {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	t.trackChildChange(keyToChild)
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		currentTx.delete(string(keyToChild))
 		return nil
@@ -365,6 +992,7 @@ func (t *TrieState) DeleteChild(keyToChild []byte) error {
 		return err
 	}
 	delete(t.childSortedKeys, string(keyToChild))
+	delete(t.staleChildSortedKeys, string(keyToChild))
 	return nil
 }
 
@@ -374,6 +1002,8 @@ func (t *TrieState) DeleteChildLimit(key []byte, limit *[]byte) (
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	t.trackChildChange(key)
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		deleteLimit := -1
 		if limit != nil {
@@ -411,30 +1041,41 @@ func (t *TrieState) DeleteChildLimit(key []byte, limit *[]byte) (
 			return 0, false, fmt.Errorf("deleting child trie: %w", err)
 		}
 		delete(t.childSortedKeys, string(key))
+		delete(t.staleChildSortedKeys, string(key))
 		return qtyEntries, true, nil
 	}
 	limitUint := binary.LittleEndian.Uint32(*limit)
 
 	keys := maps.Keys(childTrieEntries)
 	sort.Strings(keys)
+	if uint32(len(keys)) > limitUint {
+		keys = keys[:limitUint]
+	}
+
+	// Deletions are staged on a copy of the child trie, which only replaces
+	// the child trie in the state once all of them succeeded.
+	stagedChild, err := t.state.CopyChild(key)
+	if err != nil {
+		return 0, false, fmt.Errorf("copying child trie: %w", err)
+	}
+
 	for _, k := range keys {
-		// TODO have a transactional/atomic way to delete multiple keys in trie.
-		// If one deletion fails, the child trie and its parent trie are then in
-		// a bad intermediary state. Take also care of the caching of deleted Merkle
-		// values within the tries, which is used for online pruning.
-		// See https://github.com/ChainSafe/gossamer/issues/3032
-		err = child.Delete([]byte(k))
+		err = stagedChild.Delete([]byte(k))
 		if err != nil {
-			return deleted, allDeleted, fmt.Errorf("deleting from child trie located at key 0x%x: %w", key, err)
+			return 0, false, fmt.Errorf("deleting from child trie located at key 0x%x: %w", key, err)
 		}
+	}
+
+	err = t.state.ReplaceChild(key, stagedChild)
+	if err != nil {
+		return 0, false, fmt.Errorf("replacing child trie: %w", err)
+	}
 
+	for _, k := range keys {
 		t.removeChildTrieSortedKey(string(key), k)
-		deleted++
-		if deleted == limitUint {
-			break
-		}
 	}
 
+	deleted = uint32(len(keys))
 	allDeleted = deleted == qtyEntries
 	return deleted, allDeleted, nil
 }
@@ -444,6 +1085,8 @@ func (t *TrieState) ClearChildStorage(keyToChild, key []byte) error {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	t.trackChildChange(keyToChild)
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		keyToChildStr := string(keyToChild)
 		keyStr := string(key)
@@ -462,9 +1105,18 @@ func (t *TrieState) ClearChildStorage(keyToChild, key []byte) error {
 
 // ClearPrefixInChild clears all the keys from the child trie that have the given prefix
 func (t *TrieState) ClearPrefixInChild(keyToChild, prefix []byte) error {
+	_, err := t.ClearPrefixInChildCount(keyToChild, prefix)
+	return err
+}
+
+// ClearPrefixInChildCount clears all the keys from the child trie that have the given prefix
+// and returns the number of keys deleted
+func (t *TrieState) ClearPrefixInChildCount(keyToChild, prefix []byte) (deleted uint32, err error) {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	t.trackChildChange(keyToChild)
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		child, err := t.state.GetChild(keyToChild)
 		childKeys := make([]string, 0)
@@ -472,35 +1124,38 @@ func (t *TrieState) ClearPrefixInChild(keyToChild, prefix []byte) error {
 			childKeys = maps.Keys(child.Entries())
 		}
 
-		currentTx.clearPrefixInChild(string(keyToChild), prefix, childKeys, -1)
-		return nil
+		deleted, _ = currentTx.clearPrefixInChild(string(keyToChild), prefix, childKeys, -1)
+		return deleted, nil
 	}
 
 	child, err := t.state.GetChild(keyToChild)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if child == nil {
-		return nil
+		return 0, nil
 	}
 
+	deleted = uint32(len(child.GetKeysWithPrefix(prefix)))
 	err = child.ClearPrefix(prefix)
 	if err != nil {
-		return fmt.Errorf("clearing prefix in child trie located at key 0x%x: %w", keyToChild, err)
+		return 0, fmt.Errorf("clearing prefix in child trie located at key 0x%x: %w", keyToChild, err)
 	}
 	t.childSortedKeys[string(keyToChild)] = t.removePrefixedSortedKey(
 		t.childSortedKeys[string(keyToChild)],
 		string(prefix),
-		-1,
+		nil,
 	)
 
-	return nil
+	return deleted, nil
 }
 
 func (t *TrieState) ClearPrefixInChildWithLimit(keyToChild, prefix []byte, limit uint32) (uint32, bool, error) {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
+	t.trackChildChange(keyToChild)
+
 	if currentTx := t.getCurrentTransaction(); currentTx != nil {
 		child, err := t.state.GetChild(keyToChild)
 		childKeys := make([]string, 0)
@@ -524,7 +1179,9 @@ func (t *TrieState) ClearPrefixInChildWithLimit(keyToChild, prefix []byte, limit
 	t.childSortedKeys[string(keyToChild)] = t.removePrefixedSortedKey(
 		t.childSortedKeys[string(keyToChild)],
 		string(prefix),
-		-1,
+		func(key string) bool {
+			return child.Get([]byte(key)) == nil
+		},
 	)
 	return deleted, allDeleted, nil
 }
@@ -541,7 +1198,7 @@ func (t *TrieState) GetChildNextKey(keyToChild, key []byte) ([]byte, error) {
 		}
 
 		if childChanges := currentTx.childChangeSet[string(keyToChild)]; childChanges != nil {
-			mainStateChildTrieSortedKeys := t.childSortedKeys[string(keyToChild)]
+			mainStateChildTrieSortedKeys := t.childTrieSortedKeys(string(keyToChild))
 			childTrieSortedKeys := make([]string, len(mainStateChildTrieSortedKeys))
 			copy(childTrieSortedKeys, mainStateChildTrieSortedKeys)
 
@@ -580,6 +1237,87 @@ func (t *TrieState) GetChildNextKey(keyToChild, key []byte) ([]byte, error) {
 	return child.NextKey(key), nil
 }
 
+// ChildKeys returns the sorted keys of the child trie located at keyToChild,
+// taking into account the changes of the current transaction.
+func (t *TrieState) ChildKeys(keyToChild []byte) ([]string, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	var childChanges *storageDiff
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		// If we are going to delete this child we return error
+		if currentTx.deletes[string(keyToChild)] {
+			return nil, trie.ErrChildTrieDoesNotExist
+		}
+		childChanges = currentTx.childChangeSet[string(keyToChild)]
+	}
+
+	keys := make([]string, 0)
+	child, err := t.state.GetChild(keyToChild)
+	if err != nil {
+		// Child trie does not exist and won't be created by the transaction
+		if childChanges == nil {
+			return nil, err
+		}
+	} else {
+		for _, k := range child.GetKeysWithPrefix(nil) {
+			keys = append(keys, string(k))
+		}
+	}
+
+	if childChanges != nil {
+		keys = slices.DeleteFunc(keys, func(k string) bool {
+			return childChanges.deletes[k]
+		})
+
+		for k := range childChanges.upserts {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return slices.Compact(keys), nil
+}
+
+// ChildTrieView returns a read only view of the child trie located at
+// keyToChild, with the changes of the current transaction applied on top of
+// the committed child trie. The view is a snapshot and is not affected by
+// later changes to the TrieState.
+func (t *TrieState) ChildTrieView(keyToChild []byte) (ReadOnlyTrie, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	view, err := t.childTrieView(keyToChild)
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+func (t *TrieState) childTrieView(keyToChild []byte) (*childTrieView, error) {
+	var childChanges *storageDiff
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		// If we are going to delete this child we return error
+		if currentTx.deletes[string(keyToChild)] {
+			return nil, trie.ErrChildTrieDoesNotExist
+		}
+		childChanges = currentTx.childChangeSet[string(keyToChild)]
+	}
+
+	var committed map[string][]byte
+	child, err := t.state.GetChild(keyToChild)
+	if err != nil {
+		// Child trie does not exist and won't be created by the transaction
+		if childChanges == nil {
+			return nil, err
+		}
+	} else {
+		committed = child.Entries()
+	}
+
+	return newChildTrieView(committed, childChanges), nil
+}
+
 // GetKeysWithPrefixFromChild ...
 func (t *TrieState) GetKeysWithPrefixFromChild(keyToChild, prefix []byte) ([][]byte, error) {
 	t.mtx.RLock()
@@ -591,30 +1329,19 @@ func (t *TrieState) GetKeysWithPrefixFromChild(keyToChild, prefix []byte) ([][]b
 			return nil, trie.ErrChildTrieDoesNotExist
 		}
 
-		if childChanges := currentTx.childChangeSet[string(keyToChild)]; childChanges != nil {
-			allEntries := make(map[string][]byte)
-
-			maps.Copy(allEntries, childChanges.upserts)
-			child, err := t.state.GetChild(keyToChild)
+		if currentTx.childChangeSet[string(keyToChild)] != nil {
+			view, err := t.childTrieView(keyToChild)
 			if err != nil {
-				// Child trie does not exists and won't exists in the future
-				if len(allEntries) == 0 {
-					return nil, err
-				}
-			} else {
-				allEntries = child.Entries()
+				return nil, err
 			}
-			keys := maps.Keys(allEntries)
-
-			values := make([][]byte, 0)
 
-			for _, k := range keys {
-				if bytes.HasPrefix([]byte(k), prefix) {
-					values = append(values, []byte(k))
+			keys := make([][]byte, 0)
+			for _, k := range view.sortedKeys {
+				if strings.HasPrefix(k, string(prefix)) {
+					keys = append(keys, []byte(k))
 				}
 			}
-
-			return values, nil
+			return keys, nil
 		}
 	}
 
@@ -628,17 +1355,104 @@ func (t *TrieState) GetKeysWithPrefixFromChild(keyToChild, prefix []byte) ([][]b
 	return child.GetKeysWithPrefix(prefix), nil
 }
 
-// LoadCode returns the runtime code (located at :code)
+// GetChildEntriesWithPrefix returns, sorted lexicographically, the key-value pairs
+// of the child trie located at keyToChild whose keys have the given prefix and
+// are greater than startKey, up to limit pairs. The changes of the current
+// transaction take precedence over the committed child trie entries.
+func (t *TrieState) GetChildEntriesWithPrefix(keyToChild, prefix, startKey []byte, limit uint32) (
+	[]trie.Entry, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		// If we are going to delete this child we return error
+		if currentTx.deletes[string(keyToChild)] {
+			return nil, trie.ErrChildTrieDoesNotExist
+		}
+	}
+
+	view, err := t.childTrieView(keyToChild)
+	if err != nil {
+		return nil, err
+	}
+
+	// seek to the first key after startKey, or to the first prefixed key if
+	// it comes after it, so the prefixed keys are contiguous from there.
+	pos, found := slices.BinarySearch(view.sortedKeys, string(startKey))
+	if found {
+		pos++
+	}
+	prefixPos, _ := slices.BinarySearch(view.sortedKeys, string(prefix))
+	pos = max(pos, prefixPos)
+
+	entries := make([]trie.Entry, 0)
+	for _, k := range view.sortedKeys[pos:] {
+		if uint32(len(entries)) >= limit || !strings.HasPrefix(k, string(prefix)) {
+			break
+		}
+		entries = append(entries, trie.Entry{Key: []byte(k), Value: view.entries[k]})
+	}
+
+	return entries, nil
+}
+
+// LoadCode returns the runtime code (located at :code) from its configured
+// location, or nil if the configured child trie does not exist.
 func (t *TrieState) LoadCode() []byte {
-	return t.Get(common.CodeKey)
+	code, err := t.loadConfiguredCode()
+	if err != nil {
+		return nil
+	}
+	return code
+}
+
+// LoadCodeFromChild returns the runtime code located at :code
+// in the child trie located at keyToChild.
+func (t *TrieState) LoadCodeFromChild(keyToChild []byte) ([]byte, error) {
+	return t.GetChildStorage(keyToChild, common.CodeKey)
+}
+
+// SetCodeChildKey configures the child trie, located at keyToChild, from which
+// LoadCode, LoadCodeHash and HasCode load the runtime code. A nil keyToChild
+// restores the default of loading the code from the main trie.
+func (t *TrieState) SetCodeChildKey(keyToChild []byte) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.codeKeyToChild = slices.Clone(keyToChild)
+}
+
+// loadConfiguredCode returns the runtime code from the configured source,
+// which is the main trie unless a child trie was set with SetCodeChildKey.
+func (t *TrieState) loadConfiguredCode() ([]byte, error) {
+	t.mtx.RLock()
+	keyToChild := t.codeKeyToChild
+	t.mtx.RUnlock()
+
+	if keyToChild == nil {
+		return t.Get(common.CodeKey), nil
+	}
+
+	return t.LoadCodeFromChild(keyToChild)
 }
 
 // LoadCodeHash returns the hash of the runtime code (located at :code)
 func (t *TrieState) LoadCodeHash() (common.Hash, error) {
-	code := t.LoadCode()
+	code, err := t.loadConfiguredCode()
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("loading code: %w", err)
+	}
+
 	return common.Blake2bHash(code)
 }
 
+// HasCode returns true if the runtime code is present
+// at its configured location.
+func (t *TrieState) HasCode() bool {
+	code, err := t.loadConfiguredCode()
+	return err == nil && len(code) > 0
+}
+
 // GetChangedNodeHashes returns the two sets of hashes for all nodes
 // inserted and deleted in the state trie since the last block produced (trie snapshot).
 func (t *TrieState) GetChangedNodeHashes() (inserted, deleted map[common.Hash]struct{}, err error) {
@@ -648,6 +1462,81 @@ func (t *TrieState) GetChangedNodeHashes() (inserted, deleted map[common.Hash]st
 	return t.state.GetChangedNodeHashes()
 }
 
+// ChangedKeys returns the sorted main trie keys modified since the last call to
+// ResetChanges. Changes to a child trie are reported using its key in the main
+// trie, that is the child storage key prefix followed by the key to the child.
+// Keys modified within a rolled back transaction are still reported, so the
+// value of some of the keys returned may be unchanged.
+// It returns nil if change tracking is not enabled.
+func (t *TrieState) ChangedKeys() [][]byte {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	if t.changedKeys == nil {
+		return nil
+	}
+
+	keys := maps.Keys(t.changedKeys)
+	sort.Strings(keys)
+
+	changedKeys := make([][]byte, len(keys))
+	for i, k := range keys {
+		changedKeys[i] = []byte(k)
+	}
+	return changedKeys
+}
+
+// ResetChanges clears the set of keys reported by ChangedKeys.
+func (t *TrieState) ResetChanges() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.changedKeys != nil {
+		t.changedKeys = make(map[string]struct{})
+	}
+}
+
+func (t *TrieState) trackChange(key string) {
+	if t.changedKeys != nil {
+		t.changedKeys[key] = struct{}{}
+	}
+}
+
+// trackChildChange records a change to the child trie at the given key,
+// using the key of the child trie in the main trie.
+func (t *TrieState) trackChildChange(keyToChild []byte) {
+	if t.changedKeys != nil {
+		t.trackChange(string(inmemory.ChildStorageKeyPrefix) + string(keyToChild))
+	}
+}
+
+// keysWithPrefix returns the keys currently set with the given prefix
+// if change tracking is enabled.
+func (t *TrieState) keysWithPrefix(prefix []byte) (keys [][]byte) {
+	if t.changedKeys == nil {
+		return nil
+	}
+
+	keys = t.state.GetKeysWithPrefix(prefix)
+	if currentTx := t.getCurrentTransaction(); currentTx != nil {
+		for k := range currentTx.upserts {
+			if bytes.HasPrefix([]byte(k), prefix) {
+				keys = append(keys, []byte(k))
+			}
+		}
+	}
+	return keys
+}
+
+// trackDeletedKeys records the given keys which are no longer set.
+func (t *TrieState) trackDeletedKeys(keys [][]byte) {
+	for _, k := range keys {
+		if t.get(k) == nil {
+			t.trackChange(string(k))
+		}
+	}
+}
+
 func (t *TrieState) addMainTrieSortedKey(key string) {
 	t.sortedKeys = t.insertSortedKey(t.sortedKeys, key)
 }
@@ -657,13 +1546,44 @@ func (t *TrieState) removeMainTrieSortedKey(key string) {
 }
 
 func (t *TrieState) addChildTrieSortedKey(keyToChild, key string) {
+	if _, stale := t.staleChildSortedKeys[keyToChild]; stale {
+		return
+	}
 	t.childSortedKeys[keyToChild] = t.insertSortedKey(t.childSortedKeys[keyToChild], key)
 }
 
 func (t *TrieState) removeChildTrieSortedKey(keyToChild, key string) {
+	if _, stale := t.staleChildSortedKeys[keyToChild]; stale {
+		return
+	}
 	t.childSortedKeys[keyToChild] = t.removeSortedKey(t.childSortedKeys[keyToChild], key)
 }
 
+// childTrieSortedKeys returns the sorted keys of the committed child trie
+// located at keyToChild, computing them from the child trie entries if the
+// cached sorted keys were invalidated.
+func (t *TrieState) childTrieSortedKeys(keyToChild string) []string {
+	if _, stale := t.staleChildSortedKeys[keyToChild]; !stale {
+		return t.childSortedKeys[keyToChild]
+	}
+
+	child, err := t.state.GetChild([]byte(keyToChild))
+	if err != nil || child == nil {
+		return nil
+	}
+	return sortedTrieKeys(child)
+}
+
+// sortedTrieKeys returns the keys of the given trie sorted lexicographically.
+func sortedTrieKeys(tr trie.Trie) []string {
+	keys := make([]string, 0)
+	for _, key := range tr.GetKeysWithPrefix(nil) {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (t *TrieState) insertSortedKey(keys []string, key string) []string {
 	pos, found := slices.BinarySearch(keys, key)
 
@@ -677,6 +1597,33 @@ func (t *TrieState) insertSortedKey(keys []string, key string) []string {
 	return keys
 }
 
+// nextSortedKey returns the first of the sorted keys greater than key
+// for which skip, if not nil, returns false.
+func nextSortedKey(keys []string, key string, skip func(k string) bool) (next string, found bool) {
+	pos, found := slices.BinarySearch(keys, key)
+	if found {
+		pos++
+	}
+
+	for ; pos < len(keys); pos++ {
+		if skip == nil || !skip(keys[pos]) {
+			return keys[pos], true
+		}
+	}
+	return "", false
+}
+
+// mergeSortedKeys returns the sorted keys with the sorted new keys
+// added to them, without duplicates.
+func mergeSortedKeys(keys, newKeys []string) []string {
+	if len(newKeys) == 0 {
+		return keys
+	}
+	keys = append(keys, newKeys...)
+	sort.Strings(keys)
+	return slices.Compact(keys)
+}
+
 func (t *TrieState) removeSortedKey(keys []string, key string) []string {
 	pos, found := slices.BinarySearch(keys, key)
 
@@ -687,16 +1634,24 @@ func (t *TrieState) removeSortedKey(keys []string, key string) []string {
 	return keys
 }
 
-func (t *TrieState) removePrefixedSortedKey(keys []string, key string, limit int) []string {
-	amountDeleted := 0
-	for _, k := range keys {
-		if bytes.HasPrefix([]byte(k), []byte(key)) {
-			keys = t.removeSortedKey(keys, k)
-			if limit > 0 && amountDeleted == limit {
-				break
-			}
+// removePrefixedSortedKey removes the keys with the given prefix from the sorted keys.
+// If isDeleted is not nil, only the prefixed keys for which it returns true are removed.
+func (t *TrieState) removePrefixedSortedKey(keys []string, prefix string,
+	isDeleted func(key string) bool) []string {
+	// Keys with the prefix are contiguous since keys are sorted
+	start, _ := slices.BinarySearch(keys, prefix)
+	end := start
+	for end < len(keys) && strings.HasPrefix(keys[end], prefix) {
+		end++
+	}
+
+	kept := start
+	for i := start; i < end; i++ {
+		if isDeleted != nil && !isDeleted(keys[i]) {
+			keys[kept] = keys[i]
+			kept++
 		}
 	}
 
-	return keys
+	return append(keys[:kept], keys[end:]...)
 }
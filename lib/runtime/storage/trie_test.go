@@ -4,13 +4,22 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"testing"
 
 	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/ChainSafe/gossamer/pkg/trie"
 	inmemory_trie "github.com/ChainSafe/gossamer/pkg/trie/inmemory"
+	"github.com/ChainSafe/gossamer/pkg/trie/inmemory/proof"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 var testCases = []string{
@@ -175,7 +184,7 @@ func TestTrieState_WithAndWithoutTransactions(t *testing.T) {
 				if isTransactionRunning {
 					// New keys are not considered towards the limit
 					require.Equal(t, uint32(2), deleted)
-					require.False(t, allDeleted)
+					require.True(t, allDeleted)
 				} else {
 					require.Equal(t, uint32(1), deleted)
 					require.False(t, allDeleted)
@@ -216,12 +225,14 @@ func TestTrieState_WithAndWithoutTransactions(t *testing.T) {
 				deleted, allDeleted, err := ts.ClearPrefixInChildWithLimit(keyToChild, []byte("noo"), uint32(1))
 
 				require.NoError(t, err)
-				require.False(t, allDeleted)
 
 				if isTransactionRunning {
+					// New keys are not considered towards the limit
 					require.Equal(t, uint32(2), deleted)
+					require.True(t, allDeleted)
 				} else {
 					require.Equal(t, uint32(1), deleted)
+					require.False(t, allDeleted)
 				}
 			},
 		},
@@ -640,3 +651,1868 @@ func BenchmarkNextKey(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkTrieState_Get(b *testing.B) {
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+
+	const maxKeys = 1000
+	keys := make([][]byte, maxKeys)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%04d", i))
+		err := ts.Put(keys[i], keys[i])
+		require.NoError(b, err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_ = ts.Get(keys[i%maxKeys])
+			i++
+		}
+	})
+}
+
+func TestTrieState_ConcurrentGet(t *testing.T) {
+	t.Parallel()
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	require.NoError(t, ts.Put([]byte("key"), []byte("value")))
+
+	const readers = 8
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				value := ts.Get([]byte("key"))
+				require.Contains(t, [][]byte{[]byte("value"), []byte("other")}, value)
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		ts.StartTransaction()
+		require.NoError(t, ts.Put([]byte("key"), []byte("other")))
+		require.Equal(t, []byte("other"), ts.Get([]byte("key")))
+
+		if i%2 == 0 {
+			ts.RollbackTransaction()
+			require.Equal(t, []byte("value"), ts.Get([]byte("key")))
+			continue
+		}
+
+		ts.CommitTransaction()
+		require.Equal(t, []byte("other"), ts.Get([]byte("key")))
+		require.NoError(t, ts.Put([]byte("key"), []byte("value")))
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func TestTrieState_GenerateChildProof(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	for _, tc := range testCases {
+		err := ts.SetChildStorage(keyToChild, []byte(tc), []byte(tc+"-value"))
+		require.NoError(t, err)
+	}
+
+	childRoot, err := ts.GetChildRoot(keyToChild)
+	require.NoError(t, err)
+
+	keys := [][]byte{[]byte(testCases[0]), []byte(testCases[3])}
+	encodedProofNodes, err := ts.GenerateChildProof(keyToChild, keys)
+	require.NoError(t, err)
+	require.NotEmpty(t, encodedProofNodes)
+
+	for _, key := range keys {
+		value, err := ts.GetChildStorage(keyToChild, key)
+		require.NoError(t, err)
+
+		err = proof.Verify(encodedProofNodes, childRoot[:], key, value)
+		require.NoError(t, err)
+	}
+
+	_, err = ts.GenerateChildProof([]byte("fakekey"), keys)
+	require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+
+	ts.StartTransaction()
+	_, err = ts.GenerateChildProof(keyToChild, keys)
+	require.ErrorIs(t, err, ErrTransactionRunning)
+	ts.RollbackTransaction()
+}
+
+func TestTrieState_LoadCode(t *testing.T) {
+	t.Parallel()
+
+	code := []byte("runtime-code")
+	keyToChild := []byte("child")
+
+	expectedHash, err := common.Blake2bHash(code)
+	require.NoError(t, err)
+	emptyHash, err := common.Blake2bHash(nil)
+	require.NoError(t, err)
+
+	t.Run("main_trie", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		require.False(t, ts.HasCode())
+
+		hash, err := ts.LoadCodeHash()
+		require.NoError(t, err)
+		require.Equal(t, emptyHash, hash)
+
+		err = ts.Put(common.CodeKey, code)
+		require.NoError(t, err)
+
+		require.True(t, ts.HasCode())
+		require.Equal(t, code, ts.LoadCode())
+
+		hash, err = ts.LoadCodeHash()
+		require.NoError(t, err)
+		require.Equal(t, expectedHash, hash)
+	})
+
+	t.Run("child_trie", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		err := ts.Put(common.CodeKey, []byte("main trie code"))
+		require.NoError(t, err)
+
+		configuredKeyToChild := slices.Clone(keyToChild)
+		ts.SetCodeChildKey(configuredKeyToChild)
+		// the key given is copied
+		configuredKeyToChild[0]++
+
+		_, err = ts.LoadCodeFromChild(keyToChild)
+		require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+		require.False(t, ts.HasCode())
+		require.Nil(t, ts.LoadCode())
+
+		_, err = ts.LoadCodeHash()
+		require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+
+		err = ts.SetChildStorage(keyToChild, common.CodeKey, code)
+		require.NoError(t, err)
+
+		childCode, err := ts.LoadCodeFromChild(keyToChild)
+		require.NoError(t, err)
+		require.Equal(t, code, childCode)
+		require.True(t, ts.HasCode())
+		require.Equal(t, code, ts.LoadCode())
+
+		hash, err := ts.LoadCodeHash()
+		require.NoError(t, err)
+		require.Equal(t, expectedHash, hash)
+
+		// restoring the main trie as code location
+		ts.SetCodeChildKey(nil)
+		require.True(t, ts.HasCode())
+		require.Equal(t, []byte("main trie code"), ts.LoadCode())
+	})
+}
+
+func TestTrieState_ChangedKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		require.NoError(t, ts.Put([]byte("key"), []byte("value")))
+		require.Nil(t, ts.ChangedKeys())
+	})
+
+	for _, withTransaction := range []bool{false, true} {
+		withTransaction := withTransaction
+		t.Run(fmt.Sprintf("with_transaction_%t", withTransaction), func(t *testing.T) {
+			t.Parallel()
+
+			initialState := inmemory_trie.NewEmptyTrie()
+			for _, key := range []string{"noot", "noodle", "other", "untouched"} {
+				require.NoError(t, initialState.Put([]byte(key), []byte("value")))
+			}
+			require.NoError(t, initialState.PutIntoChild([]byte("child"), []byte("key"), []byte("value")))
+
+			ts := NewTrieStateWithChangeTracking(initialState)
+			require.Empty(t, ts.ChangedKeys())
+
+			if withTransaction {
+				ts.StartTransaction()
+			}
+
+			require.NoError(t, ts.Put([]byte("new"), []byte("value")))
+			require.NoError(t, ts.Delete([]byte("other")))
+			require.NoError(t, ts.ClearPrefix([]byte("noo")))
+			require.NoError(t, ts.SetChildStorage([]byte("child"), []byte("key2"), []byte("value")))
+
+			if withTransaction {
+				ts.CommitTransaction()
+			}
+
+			expected := [][]byte{
+				[]byte(":child_storage:default:child"),
+				[]byte("new"),
+				[]byte("noodle"),
+				[]byte("noot"),
+				[]byte("other"),
+			}
+			require.Equal(t, expected, ts.ChangedKeys())
+
+			ts.ResetChanges()
+			require.Empty(t, ts.ChangedKeys())
+
+			deleted, allDeleted, err := ts.ClearPrefixLimit([]byte("n"), 1)
+			require.NoError(t, err)
+			require.Equal(t, uint32(1), deleted)
+			require.True(t, allDeleted)
+			require.Equal(t, [][]byte{[]byte("new")}, ts.ChangedKeys())
+		})
+	}
+}
+
+func TestTrieState_ChangedKeys_childKeyCollision(t *testing.T) {
+	t.Parallel()
+
+	// the main trie key and the key to the child trie are the same bytes
+	key := []byte("key")
+	initialState := inmemory_trie.NewEmptyTrie()
+	require.NoError(t, initialState.Put(key, []byte("value")))
+	require.NoError(t, initialState.PutIntoChild(key, []byte("childKey"), []byte("value")))
+
+	ts := NewTrieStateWithChangeTracking(initialState)
+
+	require.NoError(t, ts.SetChildStorage(key, []byte("childKey"), []byte("other")))
+	require.Equal(t, [][]byte{[]byte(":child_storage:default:key")}, ts.ChangedKeys())
+
+	ts.ResetChanges()
+	require.NoError(t, ts.Put(key, []byte("other")))
+	require.Equal(t, [][]byte{key}, ts.ChangedKeys())
+
+	ts.ResetChanges()
+	ts.StartTransaction()
+	require.NoError(t, ts.ClearChildStorage(key, []byte("childKey")))
+	ts.RollbackTransaction()
+
+	// changes rolled back are still reported
+	require.Equal(t, [][]byte{[]byte(":child_storage:default:key")}, ts.ChangedKeys())
+}
+
+func TestTrieState_ClearPrefixInChildCount(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	for _, withTransaction := range []bool{false, true} {
+		withTransaction := withTransaction
+		t.Run(fmt.Sprintf("with_transaction_%t", withTransaction), func(t *testing.T) {
+			t.Parallel()
+
+			ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+			for _, key := range []string{"noot", "noodle", "other"} {
+				err := ts.SetChildStorage(keyToChild, []byte(key), []byte("value"))
+				require.NoError(t, err)
+			}
+
+			if withTransaction {
+				ts.StartTransaction()
+			}
+
+			deleted, err := ts.ClearPrefixInChildCount(keyToChild, []byte("noo"))
+			require.NoError(t, err)
+			require.Equal(t, uint32(2), deleted)
+
+			if withTransaction {
+				ts.CommitTransaction()
+			}
+
+			keys, err := ts.GetKeysWithPrefixFromChild(keyToChild, []byte{})
+			require.NoError(t, err)
+			require.Equal(t, [][]byte{[]byte("other")}, keys)
+		})
+	}
+}
+
+func TestTrieState_Equal(t *testing.T) {
+	t.Parallel()
+
+	newTrieState := func(t *testing.T, keys ...string) *TrieState {
+		t.Helper()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		for _, key := range keys {
+			err := ts.Put([]byte(key), []byte("value"))
+			require.NoError(t, err)
+		}
+		return ts
+	}
+
+	testCases := map[string]struct {
+		buildStates func(t *testing.T) (a, b *TrieState)
+		equal       bool
+	}{
+		"same_instance": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				ts := newTrieState(t, "key")
+				return ts, ts
+			},
+			equal: true,
+		},
+		"equal_committed_states": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				return newTrieState(t, "key1", "key2"), newTrieState(t, "key2", "key1")
+			},
+			equal: true,
+		},
+		"different_committed_states": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				return newTrieState(t, "key1"), newTrieState(t, "key2")
+			},
+		},
+		"equal_transactions": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				a, b = newTrieState(t, "key1"), newTrieState(t, "key1")
+				a.StartTransaction()
+				b.StartTransaction()
+				require.NoError(t, a.Put([]byte("key2"), []byte("value")))
+				require.NoError(t, b.Put([]byte("key2"), []byte("value")))
+				return a, b
+			},
+			equal: true,
+		},
+		"different_transactions": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				a, b = newTrieState(t, "key1"), newTrieState(t, "key1")
+				a.StartTransaction()
+				b.StartTransaction()
+				require.NoError(t, a.Put([]byte("key2"), []byte("value")))
+				require.NoError(t, b.Delete([]byte("key1")))
+				return a, b
+			},
+		},
+		"transaction_with_changes_against_committed_state": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				a, b = newTrieState(t, "key1"), newTrieState(t, "key1")
+				a.StartTransaction()
+				require.NoError(t, a.Put([]byte("key2"), []byte("value")))
+				return a, b
+			},
+		},
+		"different_pending_child_writes": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				a, b = newTrieState(t, "key1"), newTrieState(t, "key1")
+				a.StartTransaction()
+				b.StartTransaction()
+				require.NoError(t, a.SetChildStorage([]byte("child"), []byte("key"), []byte("a")))
+				require.NoError(t, b.SetChildStorage([]byte("child"), []byte("key"), []byte("b")))
+				return a, b
+			},
+		},
+		"pending_child_write_against_committed_state": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				a, b = newTrieState(t, "key1"), newTrieState(t, "key1")
+				a.StartTransaction()
+				require.NoError(t, a.SetChildStorage([]byte("child"), []byte("key"), []byte("value")))
+				return a, b
+			},
+		},
+		"equal_pending_child_writes": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				a, b = newTrieState(t, "key1"), newTrieState(t, "key1")
+				a.StartTransaction()
+				b.StartTransaction()
+				require.NoError(t, a.SetChildStorage([]byte("child"), []byte("key"), []byte("value")))
+				require.NoError(t, b.SetChildStorage([]byte("child"), []byte("key"), []byte("value")))
+				return a, b
+			},
+			equal: true,
+		},
+		"transaction_without_changes_against_committed_state": {
+			buildStates: func(t *testing.T) (a, b *TrieState) {
+				a, b = newTrieState(t, "key1"), newTrieState(t, "key1")
+				a.StartTransaction()
+				return a, b
+			},
+			equal: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			a, b := testCase.buildStates(t)
+			require.Equal(t, testCase.equal, a.Equal(b))
+			require.Equal(t, testCase.equal, b.Equal(a))
+		})
+	}
+}
+
+func TestTrieState_Equal_concurrent(t *testing.T) {
+	t.Parallel()
+
+	a := NewTrieState(inmemory_trie.NewEmptyTrie())
+	b := NewTrieState(inmemory_trie.NewEmptyTrie())
+
+	// comparing both ways while writers wait for the locks must not deadlock
+	const iterations = 200
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*TrieState{{a, b}, {b, a}} {
+		pair := pair
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				_ = pair[0].Equal(pair[1])
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				_ = pair[0].Put([]byte{byte(i)}, []byte{1})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, a.Equal(b))
+}
+
+func TestTrieState_ChildKeys(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	newTrieState := func(t *testing.T) *TrieState {
+		t.Helper()
+
+		initialState := inmemory_trie.NewEmptyTrie()
+		for _, key := range []string{"key2", "key1", "key3"} {
+			err := initialState.PutIntoChild(keyToChild, []byte(key), []byte("value"))
+			require.NoError(t, err)
+		}
+		return NewTrieState(initialState)
+	}
+
+	t.Run("committed", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		keys, err := ts.ChildKeys(keyToChild)
+		require.NoError(t, err)
+		require.Equal(t, []string{"key1", "key2", "key3"}, keys)
+	})
+
+	t.Run("child_does_not_exist", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		_, err := ts.ChildKeys([]byte("other"))
+		require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+	})
+
+	t.Run("transaction_overlay", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		ts.StartTransaction()
+		require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key0"), []byte("value")))
+		require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key1"), []byte("new value")))
+		require.NoError(t, ts.ClearChildStorage(keyToChild, []byte("key2")))
+
+		keys, err := ts.ChildKeys(keyToChild)
+		require.NoError(t, err)
+		require.Equal(t, []string{"key0", "key1", "key3"}, keys)
+	})
+
+	t.Run("child_created_in_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		ts.StartTransaction()
+		require.NoError(t, ts.SetChildStorage([]byte("other"), []byte("key"), []byte("value")))
+
+		keys, err := ts.ChildKeys([]byte("other"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"key"}, keys)
+	})
+
+	t.Run("child_deleted_in_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		ts.StartTransaction()
+		require.NoError(t, ts.DeleteChild(keyToChild))
+
+		_, err := ts.ChildKeys(keyToChild)
+		require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+	})
+}
+
+func TestTrieState_NoopWrites(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("key")
+	keyToChild := []byte("child")
+	largeValue := make([]byte, maxNoopWriteCheckSize+1)
+
+	initialState := inmemory_trie.NewEmptyTrie()
+	require.NoError(t, initialState.Put(key, []byte("value")))
+	require.NoError(t, initialState.Put([]byte("large"), largeValue))
+	require.NoError(t, initialState.PutIntoChild(keyToChild, key, []byte("value")))
+
+	ts := NewTrieStateWithChangeTracking(initialState)
+	root := ts.MustRoot()
+
+	// writes of the current value are skipped
+	require.NoError(t, ts.Put(key, []byte("value")))
+	require.NoError(t, ts.SetChildStorage(keyToChild, key, []byte("value")))
+	require.Empty(t, ts.ChangedKeys())
+	require.Equal(t, root, ts.MustRoot())
+
+	// large values are always written
+	require.NoError(t, ts.Put([]byte("large"), largeValue))
+	require.Equal(t, [][]byte{[]byte("large")}, ts.ChangedKeys())
+	require.Equal(t, root, ts.MustRoot())
+	ts.ResetChanges()
+
+	// writes in a child trie deleted by the transaction are not skipped
+	ts.StartTransaction()
+	require.NoError(t, ts.DeleteChild(keyToChild))
+	require.NoError(t, ts.SetChildStorage(keyToChild, key, []byte("value")))
+	ts.CommitTransaction()
+	value, err := ts.GetChildStorage(keyToChild, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+	ts.ResetChanges()
+
+	// writes of a new value are applied
+	require.NoError(t, ts.Put(key, []byte("new value")))
+	require.Equal(t, [][]byte{key}, ts.ChangedKeys())
+	require.NotEqual(t, root, ts.MustRoot())
+}
+
+// verifySortedKeys returns an error if the sorted keys cache
+// of the TrieState does not match the keys of its state trie.
+func (t *TrieState) verifySortedKeys() error {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	expectedKeys := maps.Keys(t.state.Entries())
+	sort.Strings(expectedKeys)
+
+	if slices.Equal(expectedKeys, t.sortedKeys) {
+		return nil
+	}
+
+	var missing, unexpected []string
+	for _, k := range expectedKeys {
+		if _, found := slices.BinarySearch(t.sortedKeys, k); !found {
+			missing = append(missing, fmt.Sprintf("0x%x", k))
+		}
+	}
+	for _, k := range t.sortedKeys {
+		if _, found := slices.BinarySearch(expectedKeys, k); !found {
+			unexpected = append(unexpected, fmt.Sprintf("0x%x", k))
+		}
+	}
+
+	return fmt.Errorf("sorted keys do not match the state keys: "+
+		"missing keys %v, unexpected keys %v, sorted keys are sorted: %t",
+		missing, unexpected, slices.IsSorted(t.sortedKeys))
+}
+
+func TestTrieState_SortedKeysInvariant(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		withTransaction bool
+		operations      func(t *testing.T, ts *TrieState)
+	}{
+		"put_and_delete": {
+			operations: func(t *testing.T, ts *TrieState) {
+				for i := 0; i < 10; i++ {
+					require.NoError(t, ts.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")))
+				}
+				require.NoError(t, ts.Delete([]byte("key3")))
+				require.NoError(t, ts.Delete([]byte("absent")))
+				require.NoError(t, ts.Put([]byte("key3"), []byte("value")))
+				require.NoError(t, ts.Delete([]byte("key0")))
+			},
+		},
+		"clear_prefix": {
+			operations: func(t *testing.T, ts *TrieState) {
+				for _, key := range []string{"aa", "ab", "abc", "abd", "b", "ba"} {
+					require.NoError(t, ts.Put([]byte(key), []byte("value")))
+				}
+				require.NoError(t, ts.ClearPrefix([]byte("ab")))
+				require.NoError(t, ts.Put([]byte("abe"), []byte("value")))
+				require.NoError(t, ts.ClearPrefix([]byte("b")))
+			},
+		},
+		"clear_prefix_limit": {
+			operations: func(t *testing.T, ts *TrieState) {
+				for _, key := range []string{"aa", "ab", "abc", "abd", "b"} {
+					require.NoError(t, ts.Put([]byte(key), []byte("value")))
+				}
+				_, _, err := ts.ClearPrefixLimit([]byte("a"), 2)
+				require.NoError(t, err)
+			},
+		},
+		"transaction": {
+			withTransaction: true,
+			operations: func(t *testing.T, ts *TrieState) {
+				for _, key := range []string{"aa", "ab", "abc", "b"} {
+					require.NoError(t, ts.Put([]byte(key), []byte("value")))
+				}
+				require.NoError(t, ts.Delete([]byte("aa")))
+				require.NoError(t, ts.ClearPrefix([]byte("ab")))
+				require.NoError(t, ts.Put([]byte("abd"), []byte("value")))
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+			if testCase.withTransaction {
+				ts.StartTransaction()
+			}
+
+			testCase.operations(t, ts)
+
+			if testCase.withTransaction {
+				ts.CommitTransaction()
+			}
+
+			require.NoError(t, ts.verifySortedKeys())
+		})
+	}
+}
+
+func TestTrieState_IterateKeysWithPrefix(t *testing.T) {
+	t.Parallel()
+
+	newTrieState := func(t *testing.T) *TrieState {
+		t.Helper()
+
+		initialState := inmemory_trie.NewEmptyTrie()
+		for _, key := range []string{"a", "ab", "abc", "abd", "b", "ba"} {
+			require.NoError(t, initialState.Put([]byte(key), []byte("value")))
+		}
+		return NewTrieState(initialState)
+	}
+
+	collectKeys := func(ts *TrieState, prefix []byte, max int) (keys []string) {
+		ts.IterateKeysWithPrefix(prefix, func(key []byte) bool {
+			keys = append(keys, string(key))
+			return len(keys) < max
+		})
+		return keys
+	}
+
+	testCases := map[string]struct {
+		prefix       []byte
+		max          int
+		transaction  func(t *testing.T, ts *TrieState)
+		expectedKeys []string
+	}{
+		"all_keys": {
+			max:          10,
+			expectedKeys: []string{"a", "ab", "abc", "abd", "b", "ba"},
+		},
+		"prefix_is_a_key": {
+			prefix:       []byte("ab"),
+			max:          10,
+			expectedKeys: []string{"ab", "abc", "abd"},
+		},
+		"prefix_is_not_a_key": {
+			prefix:       []byte("abc0"),
+			max:          10,
+			expectedKeys: nil,
+		},
+		"early_termination": {
+			prefix:       []byte("a"),
+			max:          2,
+			expectedKeys: []string{"a", "ab"},
+		},
+		"transaction_overlay": {
+			prefix: []byte("ab"),
+			max:    10,
+			transaction: func(t *testing.T, ts *TrieState) {
+				require.NoError(t, ts.Put([]byte("abb"), []byte("value")))
+				require.NoError(t, ts.Put([]byte("abc"), []byte("new value")))
+				require.NoError(t, ts.Put([]byte("abe"), []byte("value")))
+				require.NoError(t, ts.Put([]byte("ac"), []byte("value")))
+				require.NoError(t, ts.Delete([]byte("abd")))
+			},
+			expectedKeys: []string{"ab", "abb", "abc", "abe"},
+		},
+		"transaction_clear_prefix": {
+			prefix: []byte("a"),
+			max:    10,
+			transaction: func(t *testing.T, ts *TrieState) {
+				require.NoError(t, ts.ClearPrefix([]byte("ab")))
+				require.NoError(t, ts.Put([]byte("abz"), []byte("value")))
+			},
+			expectedKeys: []string{"a", "abz"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := newTrieState(t)
+			if testCase.transaction != nil {
+				ts.StartTransaction()
+				testCase.transaction(t, ts)
+			}
+
+			keys := collectKeys(ts, testCase.prefix, testCase.max)
+			require.Equal(t, testCase.expectedKeys, keys)
+		})
+	}
+}
+
+func TestTrieState_ChildTrieView(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	newTrieState := func(t *testing.T) *TrieState {
+		t.Helper()
+
+		initialState := inmemory_trie.NewEmptyTrie()
+		for _, key := range []string{"key1", "key2", "key3"} {
+			err := initialState.PutIntoChild(keyToChild, []byte(key), []byte("value"))
+			require.NoError(t, err)
+		}
+		return NewTrieState(initialState)
+	}
+
+	t.Run("committed", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		view, err := ts.ChildTrieView(keyToChild)
+		require.NoError(t, err)
+
+		require.Equal(t, []byte("value"), view.Get([]byte("key1")))
+		require.Equal(t, []byte("key2"), view.NextKey([]byte("key1")))
+		require.Nil(t, view.NextKey([]byte("key3")))
+		require.Len(t, view.Entries(), 3)
+	})
+
+	t.Run("child_does_not_exist", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		_, err := ts.ChildTrieView([]byte("other"))
+		require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+	})
+
+	t.Run("transaction_overlay", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		ts.StartTransaction()
+		require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key0"), []byte("value")))
+		require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key1"), []byte("new value")))
+		require.NoError(t, ts.ClearChildStorage(keyToChild, []byte("key2")))
+
+		view, err := ts.ChildTrieView(keyToChild)
+		require.NoError(t, err)
+
+		require.Equal(t, []byte("new value"), view.Get([]byte("key1")))
+		require.Nil(t, view.Get([]byte("key2")))
+		require.Equal(t, []byte("key0"), view.NextKey(nil))
+		require.Equal(t, []byte("key3"), view.NextKey([]byte("key1")))
+
+		expectedEntries := map[string][]byte{
+			"key0": []byte("value"),
+			"key1": []byte("new value"),
+			"key3": []byte("value"),
+		}
+		require.Equal(t, expectedEntries, view.Entries())
+
+		// the view is not affected by later changes
+		require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key4"), []byte("value")))
+		require.Nil(t, view.Get([]byte("key4")))
+	})
+
+	t.Run("child_created_in_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		ts.StartTransaction()
+		require.NoError(t, ts.SetChildStorage([]byte("other"), []byte("key"), []byte("value")))
+
+		view, err := ts.ChildTrieView([]byte("other"))
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{"key": []byte("value")}, view.Entries())
+	})
+
+	t.Run("child_deleted_in_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTrieState(t)
+		ts.StartTransaction()
+		require.NoError(t, ts.DeleteChild(keyToChild))
+
+		_, err := ts.ChildTrieView(keyToChild)
+		require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+	})
+}
+
+func TestTrieState_GetMany(t *testing.T) {
+	t.Parallel()
+
+	initialState := inmemory_trie.NewEmptyTrie()
+	for _, key := range []string{"key1", "key2", "key3"} {
+		require.NoError(t, initialState.Put([]byte(key), []byte(key)))
+	}
+	ts := NewTrieState(initialState)
+
+	keys := [][]byte{[]byte("key3"), []byte("missing"), []byte("key1"), []byte("key2"), []byte("key4")}
+
+	values := ts.GetMany(keys)
+	expected := [][]byte{[]byte("key3"), nil, []byte("key1"), []byte("key2"), nil}
+	require.Equal(t, expected, values)
+
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("key1"), []byte("new value")))
+	require.NoError(t, ts.Delete([]byte("key2")))
+	require.NoError(t, ts.Put([]byte("key4"), []byte("key4")))
+
+	values = ts.GetMany(keys)
+	expected = [][]byte{[]byte("key3"), nil, []byte("new value"), nil, []byte("key4")}
+	require.Equal(t, expected, values)
+
+	for i, key := range keys {
+		require.Equal(t, ts.Get(key), values[i])
+	}
+
+	ts.RollbackTransaction()
+
+	values = ts.GetMany(keys)
+	expected = [][]byte{[]byte("key3"), nil, []byte("key1"), []byte("key2"), nil}
+	require.Equal(t, expected, values)
+}
+
+func BenchmarkTrieState_GetMany(b *testing.B) {
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+
+	const maxKeys = 1000
+	keys := make([][]byte, maxKeys)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%04d", i))
+		err := ts.Put(keys[i], keys[i])
+		require.NoError(b, err)
+	}
+	ts.StartTransaction()
+
+	b.Run("looped_get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, key := range keys {
+				_ = ts.Get(key)
+			}
+		}
+	})
+
+	b.Run("get_many", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = ts.GetMany(keys)
+		}
+	})
+}
+
+func TestTrieState_SwapTrie(t *testing.T) {
+	t.Parallel()
+
+	t.Run("swap", func(t *testing.T) {
+		t.Parallel()
+
+		oldTrie := inmemory_trie.NewEmptyTrie()
+		require.NoError(t, oldTrie.Put([]byte("old"), []byte("value")))
+		ts := NewTrieState(oldTrie)
+
+		keyToChild := []byte("child")
+		newTrie := inmemory_trie.NewEmptyTrie()
+		require.NoError(t, newTrie.Put([]byte("key2"), []byte("value2")))
+		require.NoError(t, newTrie.Put([]byte("key1"), []byte("value1")))
+		require.NoError(t, newTrie.PutIntoChild(keyToChild, []byte("child2"), []byte("value")))
+		require.NoError(t, newTrie.PutIntoChild(keyToChild, []byte("child1"), []byte("value")))
+
+		swapped, err := ts.SwapTrie(newTrie)
+		require.NoError(t, err)
+		require.Same(t, oldTrie, swapped)
+
+		require.Same(t, newTrie, ts.Trie())
+		require.Nil(t, ts.Get([]byte("old")))
+		require.Equal(t, []byte("value1"), ts.Get([]byte("key1")))
+		require.NoError(t, ts.verifySortedKeys())
+		require.Equal(t, []string{"child1", "child2"}, ts.childSortedKeys[string(keyToChild)])
+
+		// the rebuilt sorted keys are used by transactions
+		ts.StartTransaction()
+		require.NoError(t, ts.Put([]byte("key0"), []byte("value0")))
+		require.Equal(t, []byte("key1"), ts.NextKey([]byte("key0")))
+		require.NoError(t, ts.SetChildStorage(keyToChild, []byte("child0"), []byte("value")))
+		nextKey, err := ts.GetChildNextKey(keyToChild, []byte("child0"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("child1"), nextKey)
+	})
+
+	t.Run("transaction_running", func(t *testing.T) {
+		t.Parallel()
+
+		oldTrie := inmemory_trie.NewEmptyTrie()
+		ts := NewTrieState(oldTrie)
+		ts.StartTransaction()
+
+		swapped, err := ts.SwapTrie(inmemory_trie.NewEmptyTrie())
+		require.ErrorIs(t, err, ErrTransactionRunning)
+		require.EqualError(t, err, "swapping trie: transaction running")
+		require.Nil(t, swapped)
+		require.Same(t, oldTrie, ts.Trie())
+	})
+}
+
+func TestTrieState_DeepClone(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	initialState := inmemory_trie.NewEmptyTrie()
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		require.NoError(t, initialState.Put(key, key))
+		require.NoError(t, initialState.PutIntoChild(keyToChild, key, key))
+	}
+	original := NewTrieState(initialState)
+	original.StartTransaction()
+	require.NoError(t, original.Put([]byte("pending"), []byte("value")))
+	require.NoError(t, original.Delete([]byte("key000")))
+
+	originalRoot, err := original.state.Hash()
+	require.NoError(t, err)
+	originalEntries := original.TrieEntries()
+
+	const clones = 4
+	var wg sync.WaitGroup
+	wg.Add(clones)
+	for i := 0; i < clones; i++ {
+		clone := original.DeepClone()
+		go func(i int) {
+			defer wg.Done()
+
+			clone.StartTransaction()
+			for j := 0; j < 100; j++ {
+				key := []byte(fmt.Sprintf("key%03d", j))
+				assert.NoError(t, clone.Put(key, []byte{byte(i)}))
+				assert.NoError(t, clone.SetChildStorage(keyToChild, key, []byte{byte(i)}))
+			}
+			assert.NoError(t, clone.ClearPrefix([]byte("key09")))
+			clone.CommitTransaction()
+			clone.CommitTransaction()
+
+			_, err := clone.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("value"), clone.Get([]byte("pending")))
+			assert.Equal(t, []byte{byte(i)}, clone.Get([]byte("key000")))
+			assert.Nil(t, clone.Get([]byte("key095")))
+		}(i)
+	}
+
+	// the original is read concurrently with the clones being modified
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		value, err := original.GetChildStorage(keyToChild, key)
+		require.NoError(t, err)
+		require.Equal(t, key, value)
+	}
+	wg.Wait()
+
+	root, err := original.state.Hash()
+	require.NoError(t, err)
+	require.Equal(t, originalRoot, root)
+	require.Equal(t, originalEntries, original.TrieEntries())
+
+	original.CommitTransaction()
+	require.Equal(t, []byte("value"), original.Get([]byte("pending")))
+	require.Nil(t, original.Get([]byte("key000")))
+	require.Equal(t, []byte("key095"), original.Get([]byte("key095")))
+}
+
+// failingPutTrie is an in-memory trie failing to put the failKey.
+type failingPutTrie struct {
+	*inmemory_trie.InMemoryTrie
+	failKey string
+}
+
+func (f *failingPutTrie) Put(key, value []byte) error {
+	if string(key) == f.failKey {
+		return errors.New("test error")
+	}
+	return f.InMemoryTrie.Put(key, value)
+}
+
+func TestTrieState_PutBatch(t *testing.T) {
+	t.Parallel()
+
+	entries := map[string][]byte{
+		"key3": []byte("value3"),
+		"key1": []byte("value1"),
+		"key2": []byte("value2"),
+	}
+
+	t.Run("without_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		require.NoError(t, ts.Put([]byte("key0"), []byte("value0")))
+
+		err := ts.PutBatch(entries)
+		require.NoError(t, err)
+
+		for key, value := range entries {
+			require.Equal(t, value, ts.Get([]byte(key)))
+		}
+		require.Equal(t, []byte("key1"), ts.NextKey([]byte("key0")))
+		require.NoError(t, ts.verifySortedKeys())
+	})
+
+	t.Run("with_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		require.NoError(t, ts.Put([]byte("key0"), []byte("value0")))
+		ts.StartTransaction()
+		require.NoError(t, ts.Delete([]byte("key2")))
+
+		err := ts.PutBatch(entries)
+		require.NoError(t, err)
+
+		for key, value := range entries {
+			require.Equal(t, value, ts.Get([]byte(key)))
+		}
+		require.Equal(t, []byte("key2"), ts.NextKey([]byte("key1")))
+
+		ts.CommitTransaction()
+		require.Equal(t, []byte("value2"), ts.Get([]byte("key2")))
+		require.NoError(t, ts.verifySortedKeys())
+	})
+
+	t.Run("rolled_back", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		ts.StartTransaction()
+		require.NoError(t, ts.PutBatch(entries))
+		ts.RollbackTransaction()
+
+		require.Empty(t, ts.TrieEntries())
+		require.NoError(t, ts.verifySortedKeys())
+	})
+
+	t.Run("put_error", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(&failingPutTrie{
+			InMemoryTrie: inmemory_trie.NewEmptyTrie(),
+			failKey:      "key2",
+		})
+
+		err := ts.PutBatch(entries)
+		require.EqualError(t, err, "putting key 0x6b657932: test error")
+
+		// keys are written in sorted order until the error
+		require.Equal(t, []byte("value1"), ts.Get([]byte("key1")))
+		require.Nil(t, ts.Get([]byte("key2")))
+		require.Nil(t, ts.Get([]byte("key3")))
+		require.NoError(t, ts.verifySortedKeys())
+	})
+}
+
+func BenchmarkTrieState_PutBatch(b *testing.B) {
+	const maxKeys = 10000
+	entries := make(map[string][]byte, maxKeys)
+	for i := 0; i < maxKeys; i++ {
+		key := fmt.Sprintf("key%05d", i)
+		entries[key] = []byte(key)
+	}
+
+	b.Run("looped_put", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+			for key, value := range entries {
+				err := ts.Put([]byte(key), value)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	b.Run("put_batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+			err := ts.PutBatch(entries)
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("looped_put_in_transaction", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+			ts.StartTransaction()
+			for key, value := range entries {
+				err := ts.Put([]byte(key), value)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	b.Run("put_batch_in_transaction", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+			ts.StartTransaction()
+			err := ts.PutBatch(entries)
+			require.NoError(b, err)
+		}
+	})
+}
+
+func TestTrieState_IterateEntries(t *testing.T) {
+	t.Parallel()
+
+	initialState := inmemory_trie.NewEmptyTrie()
+	for _, key := range []string{"a", "ab", "abc", "b"} {
+		require.NoError(t, initialState.Put([]byte(key), []byte("value "+key)))
+	}
+	ts := NewTrieState(initialState)
+
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("aa"), []byte("new value aa")))
+	require.NoError(t, ts.Put([]byte("ab"), []byte("new value ab")))
+	require.NoError(t, ts.Delete([]byte("abc")))
+
+	var keys []string
+	entries := make(map[string][]byte)
+	ts.IterateEntries(nil, func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		entries[string(key)] = value
+		return true
+	})
+
+	require.Equal(t, []string{"a", "aa", "ab", "b"}, keys)
+	require.Equal(t, ts.TrieEntries(), entries)
+
+	// with a prefix and stopping early
+	keys = nil
+	ts.IterateEntries([]byte("a"), func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return len(keys) < 2
+	})
+	require.Equal(t, []string{"a", "aa"}, keys)
+}
+
+func TestTrieState_NextKey_transactionKeyspace(t *testing.T) {
+	t.Parallel()
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	for _, key := range []string{"a", "c", "d", "f", "h"} {
+		require.NoError(t, ts.Put([]byte(key), []byte("value")))
+	}
+
+	ts.StartTransaction()
+	// keys both in the state and in the transaction
+	require.NoError(t, ts.Put([]byte("c"), []byte("new value")))
+	require.NoError(t, ts.Put([]byte("h"), []byte("new value")))
+	// keys only in the transaction
+	require.NoError(t, ts.Put([]byte("b"), []byte("value")))
+	require.NoError(t, ts.Put([]byte("e"), []byte("value")))
+	require.NoError(t, ts.Put([]byte("i"), []byte("value")))
+	// deleted state keys
+	require.NoError(t, ts.Delete([]byte("a")))
+	require.NoError(t, ts.Delete([]byte("f")))
+
+	var keys []string
+	for key := ts.NextKey(nil); key != nil; key = ts.NextKey(key) {
+		keys = append(keys, string(key))
+	}
+
+	expectedKeys := maps.Keys(ts.TrieEntries())
+	sort.Strings(expectedKeys)
+	require.Equal(t, expectedKeys, keys)
+	require.Equal(t, []string{"b", "c", "d", "e", "h", "i"}, keys)
+}
+
+func TestTrieState_RootWithPendingTransactions(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+	initialState := inmemory_trie.NewEmptyTrie()
+	for _, key := range []string{"key1", "key2", "key3"} {
+		require.NoError(t, initialState.Put([]byte(key), []byte(key)))
+		require.NoError(t, initialState.PutIntoChild(keyToChild, []byte(key), []byte(key)))
+	}
+	ts := NewTrieState(initialState)
+
+	committedRoot, err := ts.Root()
+	require.NoError(t, err)
+
+	root, err := ts.RootWithPendingTransactions()
+	require.NoError(t, err)
+	require.Equal(t, committedRoot, root)
+
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("key4"), []byte("key4")))
+	require.NoError(t, ts.Delete([]byte("key1")))
+	ts.StartTransaction()
+	require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key4"), []byte("key4")))
+	require.NoError(t, ts.ClearChildStorage(keyToChild, []byte("key2")))
+
+	// the expected root is the one of a copy with all transactions committed
+	expected := ts.DeepClone()
+	expected.CommitTransaction()
+	expected.CommitTransaction()
+	expectedRoot, err := expected.Root()
+	require.NoError(t, err)
+	require.NotEqual(t, committedRoot, expectedRoot)
+
+	root, err = ts.RootWithPendingTransactions()
+	require.NoError(t, err)
+	require.Equal(t, expectedRoot, root)
+
+	// neither the trie nor the transactions are modified
+	stateRoot, err := ts.state.Hash()
+	require.NoError(t, err)
+	require.Equal(t, committedRoot, stateRoot)
+	require.Equal(t, []byte("key1"), ts.state.Get([]byte("key1")))
+	require.Nil(t, ts.Get([]byte("key1")))
+
+	ts.RollbackTransaction()
+	ts.RollbackTransaction()
+	root, err = ts.Root()
+	require.NoError(t, err)
+	require.Equal(t, committedRoot, root)
+}
+
+// failingDeleteTrie is an in-memory trie failing on its failAt-th deletion.
+type failingDeleteTrie struct {
+	*inmemory_trie.InMemoryTrie
+	deletes int
+	failAt  int
+}
+
+func (f *failingDeleteTrie) Delete(key []byte) error {
+	f.deletes++
+	if f.deletes == f.failAt {
+		return errors.New("test error")
+	}
+	return f.InMemoryTrie.Delete(key)
+}
+
+// failingChildDeleteTrie is an in-memory trie whose child trie copies
+// fail on their failAt-th deletion.
+type failingChildDeleteTrie struct {
+	*inmemory_trie.InMemoryTrie
+	failAt int
+}
+
+func (f *failingChildDeleteTrie) CopyChild(keyToChild []byte) (trie.Trie, error) {
+	child, err := f.InMemoryTrie.CopyChild(keyToChild)
+	if err != nil {
+		return nil, err
+	}
+	return &failingDeleteTrie{
+		InMemoryTrie: child.(*inmemory_trie.InMemoryTrie),
+		failAt:       f.failAt,
+	}, nil
+}
+
+func (f *failingChildDeleteTrie) ReplaceChild(keyToChild []byte, child trie.Trie) error {
+	return f.InMemoryTrie.ReplaceChild(keyToChild, child.(*failingDeleteTrie).InMemoryTrie)
+}
+
+func TestTrieState_DeleteChildLimit_atomic(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+	childKeys := []string{"key1", "key2", "key3", "key4"}
+
+	ts := NewTrieState(&failingChildDeleteTrie{
+		InMemoryTrie: inmemory_trie.NewEmptyTrie(),
+		failAt:       3,
+	})
+	for _, key := range childKeys {
+		err := ts.SetChildStorage(keyToChild, []byte(key), []byte("value"))
+		require.NoError(t, err)
+	}
+
+	child, err := ts.state.GetChild(keyToChild)
+	require.NoError(t, err)
+	expectedEntries := child.Entries()
+	expectedRoot := ts.MustRoot()
+
+	limit := make([]byte, 4)
+	binary.LittleEndian.PutUint32(limit, 4)
+
+	deleted, allDeleted, err := ts.DeleteChildLimit(keyToChild, &limit)
+	require.EqualError(t, err, "deleting from child trie located at key 0x6368696c64: test error")
+	assert.Zero(t, deleted)
+	assert.False(t, allDeleted)
+
+	child, err = ts.state.GetChild(keyToChild)
+	require.NoError(t, err)
+	assert.Equal(t, expectedEntries, child.Entries())
+	assert.Equal(t, expectedRoot, ts.MustRoot())
+	assert.Equal(t, childKeys, ts.childSortedKeys[string(keyToChild)])
+
+	// deletions within the limit succeed and are applied at once
+	binary.LittleEndian.PutUint32(limit, 2)
+
+	deleted, allDeleted, err = ts.DeleteChildLimit(keyToChild, &limit)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), deleted)
+	assert.False(t, allDeleted)
+
+	child, err = ts.state.GetChild(keyToChild)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"key3": []byte("value"),
+		"key4": []byte("value"),
+	}, child.Entries())
+	assert.NotEqual(t, expectedRoot, ts.MustRoot())
+	assert.Equal(t, []string{"key3", "key4"}, ts.childSortedKeys[string(keyToChild)])
+}
+
+func TestTrieState_InvalidateChildSortedKeys(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	for _, key := range []string{"key1", "key3"} {
+		err := ts.SetChildStorage(keyToChild, []byte(key), []byte("value"))
+		require.NoError(t, err)
+	}
+
+	// rewrite the child trie behind the back of the TrieState
+	err := ts.state.PutIntoChild(keyToChild, []byte("key2"), []byte("value"))
+	require.NoError(t, err)
+
+	ts.StartTransaction()
+	err = ts.SetChildStorage(keyToChild, []byte("key4"), []byte("value"))
+	require.NoError(t, err)
+
+	// the stale sorted keys miss key2
+	next, err := ts.GetChildNextKey(keyToChild, []byte("key1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key3"), next)
+
+	ts.InvalidateChildSortedKeys(keyToChild)
+
+	next, err = ts.GetChildNextKey(keyToChild, []byte("key1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key2"), next)
+
+	ts.CommitTransaction()
+
+	err = ts.RebuildChildSortedKeys(keyToChild)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key1", "key2", "key3", "key4"}, ts.childSortedKeys[string(keyToChild)])
+
+	ts.StartTransaction()
+	err = ts.SetChildStorage(keyToChild, []byte("key0"), []byte("value"))
+	require.NoError(t, err)
+
+	var keys []string
+	for key := []byte(""); ; {
+		key, err = ts.GetChildNextKey(keyToChild, key)
+		require.NoError(t, err)
+		if key == nil {
+			break
+		}
+		keys = append(keys, string(key))
+	}
+	assert.Equal(t, []string{"key0", "key1", "key2", "key3", "key4"}, keys)
+
+	err = ts.RebuildChildSortedKeys([]byte("nonexistent"))
+	require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+}
+
+func TestTrieState_GetChildEntriesWithPrefix(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	for _, key := range []string{"a", "pre1", "pre2", "pre3", "z"} {
+		err := ts.SetChildStorage(keyToChild, []byte(key), []byte("stored"))
+		require.NoError(t, err)
+	}
+
+	ts.StartTransaction()
+	err := ts.SetChildStorage(keyToChild, []byte("pre2"), []byte("pending"))
+	require.NoError(t, err)
+	err = ts.SetChildStorage(keyToChild, []byte("pre4"), []byte("pending"))
+	require.NoError(t, err)
+	err = ts.ClearChildStorage(keyToChild, []byte("pre3"))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		prefix   []byte
+		startKey []byte
+		limit    uint32
+		expected []trie.Entry
+	}{
+		"all_prefixed_entries": {
+			prefix: []byte("pre"),
+			limit:  10,
+			expected: []trie.Entry{
+				{Key: []byte("pre1"), Value: []byte("stored")},
+				{Key: []byte("pre2"), Value: []byte("pending")},
+				{Key: []byte("pre4"), Value: []byte("pending")},
+			},
+		},
+		"limited": {
+			prefix: []byte("pre"),
+			limit:  2,
+			expected: []trie.Entry{
+				{Key: []byte("pre1"), Value: []byte("stored")},
+				{Key: []byte("pre2"), Value: []byte("pending")},
+			},
+		},
+		"after_start_key": {
+			prefix:   []byte("pre"),
+			startKey: []byte("pre2"),
+			limit:    10,
+			expected: []trie.Entry{
+				{Key: []byte("pre4"), Value: []byte("pending")},
+			},
+		},
+		"start_key_before_prefix": {
+			prefix:   []byte("pre"),
+			startKey: []byte("a"),
+			limit:    1,
+			expected: []trie.Entry{
+				{Key: []byte("pre1"), Value: []byte("stored")},
+			},
+		},
+		"prefix_being_a_key": {
+			prefix: []byte("pre1"),
+			limit:  10,
+			expected: []trie.Entry{
+				{Key: []byte("pre1"), Value: []byte("stored")},
+			},
+		},
+		"start_key_after_prefixed_keys": {
+			prefix:   []byte("pre"),
+			startKey: []byte("pre9"),
+			limit:    10,
+			expected: []trie.Entry{},
+		},
+		"zero_limit": {
+			prefix:   []byte("pre"),
+			expected: []trie.Entry{},
+		},
+		"no_match": {
+			prefix:   []byte("none"),
+			limit:    10,
+			expected: []trie.Entry{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := ts.GetChildEntriesWithPrefix(keyToChild, testCase.prefix, testCase.startKey, testCase.limit)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, entries)
+		})
+	}
+
+	t.Run("keys_with_prefix_include_pending_writes", func(t *testing.T) {
+		t.Parallel()
+
+		keys, err := ts.GetKeysWithPrefixFromChild(keyToChild, []byte("pre"))
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("pre1"), []byte("pre2"), []byte("pre4")}, keys)
+	})
+
+	t.Run("child_not_exists", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ts.GetChildEntriesWithPrefix([]byte("other"), nil, nil, 10)
+		require.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+	})
+}
+
+func TestTrieState_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	require.NoError(t, ts.Put([]byte("committed"), []byte("value")))
+	require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key"), []byte("value")))
+
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("outer"), []byte("value")))
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("inner"), []byte("value")))
+
+	snapshot := ts.Snapshot()
+	expectedRoot, err := ts.RootWithPendingTransactions()
+	require.NoError(t, err)
+
+	// speculative changes at various depths, including committing
+	// the whole transaction stack to the underlying trie
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("speculative"), []byte("value")))
+	require.NoError(t, ts.Delete([]byte("outer")))
+	ts.CommitTransaction()
+	ts.CommitTransaction()
+	ts.CommitTransaction()
+	require.NoError(t, ts.Put([]byte("committed"), []byte("overwritten")))
+	require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key"), []byte("overwritten")))
+
+	for i := 0; i < 2; i++ {
+		ts.Restore(snapshot)
+
+		assert.Equal(t, int32(2), ts.transactionDepth.Load())
+		assert.Equal(t, 2, ts.transactions.Len())
+		assert.Equal(t, []byte("value"), ts.Get([]byte("committed")))
+		assert.Equal(t, []byte("value"), ts.Get([]byte("outer")))
+		assert.Equal(t, []byte("value"), ts.Get([]byte("inner")))
+		assert.Nil(t, ts.Get([]byte("speculative")))
+		childValue, err := ts.GetChildStorage(keyToChild, []byte("key"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), childValue)
+
+		root, err := ts.RootWithPendingTransactions()
+		require.NoError(t, err)
+		assert.Equal(t, expectedRoot, root)
+
+		// the restored transactions are usable and do not alter the snapshot
+		ts.RollbackTransaction()
+		assert.Nil(t, ts.Get([]byte("inner")))
+		require.NoError(t, ts.Put([]byte("outer"), []byte("changed")))
+		ts.CommitTransaction()
+		assert.Equal(t, []byte("changed"), ts.Get([]byte("outer")))
+	}
+}
+
+func TestTrieState_SnapshotRestore_changedNodeHashes(t *testing.T) {
+	t.Parallel()
+
+	changes := func(t *testing.T, ts *TrieState) {
+		t.Helper()
+		require.NoError(t, ts.Put([]byte("key4"), bytes.Repeat([]byte("key4"), 10)))
+	}
+
+	expected, _ := newPersistedTrieState(t)
+	require.NoError(t, expected.Delete([]byte("key1")))
+	changes(t, expected)
+	expectedInserted, expectedDeleted, err := expected.GetChangedNodeHashes()
+	require.NoError(t, err)
+	require.NotEmpty(t, expectedDeleted)
+
+	testCases := map[string]func(t *testing.T, ts *TrieState){
+		"snapshot": func(t *testing.T, ts *TrieState) {
+			require.NoError(t, ts.Delete([]byte("key1")))
+			_ = ts.Snapshot()
+			changes(t, ts)
+		},
+		"restore": func(t *testing.T, ts *TrieState) {
+			require.NoError(t, ts.Delete([]byte("key1")))
+			snapshot := ts.Snapshot()
+			require.NoError(t, ts.Delete([]byte("key2")))
+			ts.Restore(snapshot)
+			changes(t, ts)
+		},
+	}
+
+	for name, apply := range testCases {
+		apply := apply
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ts, _ := newPersistedTrieState(t)
+			apply(t, ts)
+
+			inserted, deleted, err := ts.GetChangedNodeHashes()
+			require.NoError(t, err)
+			assert.Equal(t, expectedDeleted, deleted)
+			assert.Equal(t, expectedInserted, inserted)
+		})
+	}
+}
+
+func TestTrieState_PendingChangeCount(t *testing.T) {
+	t.Parallel()
+
+	keyToChild := []byte("child")
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	require.NoError(t, ts.Put([]byte("committed"), []byte("value")))
+
+	upserts, deletes, childChanges := ts.PendingChangeCount()
+	assert.Zero(t, upserts)
+	assert.Zero(t, deletes)
+	assert.Zero(t, childChanges)
+
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("key1"), []byte("value")))
+	require.NoError(t, ts.Put([]byte("key2"), []byte("value")))
+	require.NoError(t, ts.Delete([]byte("committed")))
+
+	ts.StartTransaction()
+	require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key1"), []byte("value")))
+	require.NoError(t, ts.SetChildStorage(keyToChild, []byte("key2"), []byte("value")))
+	require.NoError(t, ts.ClearChildStorage(keyToChild, []byte("key3")))
+
+	upserts, deletes, childChanges = ts.PendingChangeCount()
+	assert.Equal(t, 2, upserts)
+	assert.Equal(t, 1, deletes)
+	assert.Equal(t, 3, childChanges)
+
+	ts.RollbackTransaction()
+
+	upserts, deletes, childChanges = ts.PendingChangeCount()
+	assert.Equal(t, 2, upserts)
+	assert.Equal(t, 1, deletes)
+	assert.Zero(t, childChanges)
+}
+
+func TestTrieState_ClearPrefixLimit_transaction(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keys_put_in_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		require.NoError(t, ts.Put([]byte("bar"), []byte("value")))
+
+		ts.StartTransaction()
+		require.NoError(t, ts.Put([]byte("foo1"), []byte("value")))
+		require.NoError(t, ts.Put([]byte("foo2"), []byte("value")))
+
+		deleted, allDeleted, err := ts.ClearPrefixLimit([]byte("foo"), 10)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), deleted)
+		assert.True(t, allDeleted)
+		assert.Nil(t, ts.Get([]byte("foo1")))
+		assert.Nil(t, ts.Get([]byte("foo2")))
+		assert.Equal(t, []byte("value"), ts.Get([]byte("bar")))
+	})
+
+	t.Run("stored_keys_updated_or_deleted_in_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		for _, key := range []string{"foo1", "foo2", "foo3"} {
+			require.NoError(t, ts.Put([]byte(key), []byte("value")))
+		}
+
+		ts.StartTransaction()
+		require.NoError(t, ts.Put([]byte("foo1"), []byte("updated")))
+		require.NoError(t, ts.Delete([]byte("foo2")))
+
+		deleted, allDeleted, err := ts.ClearPrefixLimit([]byte("foo"), 10)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), deleted)
+		assert.True(t, allDeleted)
+	})
+
+	t.Run("limit_reached", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		for _, key := range []string{"foo1", "foo2"} {
+			require.NoError(t, ts.Put([]byte(key), []byte("value")))
+		}
+
+		ts.StartTransaction()
+		require.NoError(t, ts.Put([]byte("foo0"), []byte("value")))
+
+		deleted, allDeleted, err := ts.ClearPrefixLimit([]byte("foo"), 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), deleted)
+		assert.False(t, allDeleted)
+		assert.Equal(t, []byte("value"), ts.Get([]byte("foo2")))
+	})
+}
+
+func TestTrieState_GetWithPresence(t *testing.T) {
+	t.Parallel()
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	require.NoError(t, ts.Put([]byte("empty"), []byte{}))
+	require.NoError(t, ts.Put([]byte("nonempty"), []byte("value")))
+	require.NoError(t, ts.Put([]byte("deleted_in_tx"), []byte("value")))
+
+	type expectation struct {
+		value   []byte
+		present bool
+	}
+
+	check := func(t *testing.T, expected map[string]expectation) {
+		t.Helper()
+		for key, expected := range expected {
+			value, present := ts.GetWithPresence([]byte(key))
+			assert.Equal(t, expected.present, present, key)
+			assert.Equal(t, expected.value, value, key)
+		}
+	}
+
+	check(t, map[string]expectation{
+		"absent":        {},
+		"empty":         {value: []byte{}, present: true},
+		"nonempty":      {value: []byte("value"), present: true},
+		"deleted_in_tx": {value: []byte("value"), present: true},
+	})
+
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("absent"), []byte{}))
+	require.NoError(t, ts.Put([]byte("empty"), []byte("value")))
+	require.NoError(t, ts.Put([]byte("nonempty"), []byte{}))
+	require.NoError(t, ts.Delete([]byte("deleted_in_tx")))
+
+	check(t, map[string]expectation{
+		"absent":        {value: []byte{}, present: true},
+		"empty":         {value: []byte("value"), present: true},
+		"nonempty":      {value: []byte{}, present: true},
+		"deleted_in_tx": {},
+	})
+
+	ts.RollbackTransaction()
+
+	check(t, map[string]expectation{
+		"absent":        {},
+		"empty":         {value: []byte{}, present: true},
+		"nonempty":      {value: []byte("value"), present: true},
+		"deleted_in_tx": {value: []byte("value"), present: true},
+	})
+}
+
+func TestTrieState_CountKeysWithPrefix(t *testing.T) {
+	t.Parallel()
+
+	ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+	for _, key := range []string{"pre1", "pre2", "pre3", "other"} {
+		require.NoError(t, ts.Put([]byte(key), []byte("value")))
+	}
+
+	assert.Equal(t, uint32(0), ts.CountKeysWithPrefix([]byte("none")))
+	assert.Equal(t, uint32(3), ts.CountKeysWithPrefix([]byte("pre")))
+	assert.Equal(t, uint32(4), ts.CountKeysWithPrefix(nil))
+
+	ts.StartTransaction()
+	require.NoError(t, ts.Put([]byte("pre0"), []byte("value")))
+	require.NoError(t, ts.Put([]byte("pre1"), []byte("updated")))
+	require.NoError(t, ts.Delete([]byte("pre3")))
+	require.NoError(t, ts.Put([]byte("none"), []byte("value")))
+	require.NoError(t, ts.Delete([]byte("none")))
+
+	assert.Equal(t, uint32(0), ts.CountKeysWithPrefix([]byte("none")))
+	assert.Equal(t, uint32(3), ts.CountKeysWithPrefix([]byte("pre")))
+	assert.Equal(t, uint32(1), ts.CountKeysWithPrefix([]byte("pre0")))
+	assert.Equal(t, uint32(4), ts.CountKeysWithPrefix(nil))
+
+	ts.CommitTransaction()
+
+	assert.Equal(t, uint32(3), ts.CountKeysWithPrefix([]byte("pre")))
+	assert.Equal(t, uint32(4), ts.CountKeysWithPrefix(nil))
+}
+
+func TestTrieState_WithTransaction(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("test error")
+
+	t.Run("commit", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		err := ts.WithTransaction(func() error {
+			assert.Equal(t, 1, ts.transactions.Len())
+			return ts.Put([]byte("key"), []byte("value"))
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, ts.transactions.Len())
+		assert.Equal(t, int32(0), ts.transactionDepth.Load())
+		assert.Equal(t, []byte("value"), ts.state.Get([]byte("key")))
+	})
+
+	t.Run("nested_commit_merges_into_parent", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		ts.StartTransaction()
+		err := ts.WithTransaction(func() error {
+			return ts.Put([]byte("key"), []byte("value"))
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, ts.transactions.Len())
+		assert.Equal(t, []byte("value"), ts.Get([]byte("key")))
+		assert.Nil(t, ts.state.Get([]byte("key")))
+	})
+
+	t.Run("error_rolls_back", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		err := ts.WithTransaction(func() error {
+			require.NoError(t, ts.Put([]byte("key"), []byte("value")))
+			return errTest
+		})
+		require.ErrorIs(t, err, errTest)
+
+		assert.Equal(t, 0, ts.transactions.Len())
+		assert.Equal(t, int32(0), ts.transactionDepth.Load())
+		assert.Nil(t, ts.Get([]byte("key")))
+	})
+
+	t.Run("panic_rolls_back", func(t *testing.T) {
+		t.Parallel()
+
+		ts := NewTrieState(inmemory_trie.NewEmptyTrie())
+		ts.StartTransaction()
+		assert.PanicsWithValue(t, "test panic", func() {
+			_ = ts.WithTransaction(func() error {
+				require.NoError(t, ts.Put([]byte("key"), []byte("value")))
+				panic("test panic")
+			})
+		})
+
+		assert.Equal(t, 1, ts.transactions.Len())
+		assert.Equal(t, int32(1), ts.transactionDepth.Load())
+		assert.Nil(t, ts.Get([]byte("key")))
+	})
+}
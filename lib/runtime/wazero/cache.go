@@ -0,0 +1,171 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package wazero_runtime
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/tetratelabs/wazero"
+)
+
+// maxCompiledRuntimes is the number of runtime codes kept compiled once no
+// instance uses them, which covers the runtimes in use around an upgrade.
+const maxCompiledRuntimes = 4
+
+// compiledRuntimes holds the compiled runtimes of the codes most recently
+// instantiated, so new instances of a code reuse its compiled host and guest
+// modules instead of compiling them again.
+var compiledRuntimes = newCompiledRuntimeCache(maxCompiledRuntimes)
+
+// compiledRuntime holds the host and guest modules compiled for a runtime
+// code, along with the compilation cache holding their machine code.
+type compiledRuntime struct {
+	codeHash common.Hash
+	cache    wazero.CompilationCache
+
+	mtx         sync.Mutex
+	hostModule  wazero.CompiledModule
+	guestModule wazero.CompiledModule
+
+	// instances and element are guarded by the compiledRuntimeCache mutex.
+	instances uint
+	// element is the element of the compiledRuntime in the least recently
+	// used list, and is nil once evicted.
+	element *list.Element
+}
+
+// modules returns the compiled host and guest modules, compiling them with
+// the given runtime if they are not compiled yet.
+// Both modules are compiled by the same runtime, so the function type IDs
+// assigned by its store are consistent between them when instantiated by
+// other runtimes sharing the compilation cache.
+func (c *compiledRuntime) modules(ctx context.Context, rt wazero.Runtime, code []byte) (
+	hostModule, guestModule wazero.CompiledModule, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.guestModule != nil {
+		return c.hostModule, c.guestModule, nil
+	}
+
+	code, err = decompressWasm(code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decompressing wasm code: %w", err)
+	}
+
+	hostModule, err = compileHostModule(ctx, rt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compiling host module: %w", err)
+	}
+
+	guestModule, err = rt.CompileModule(ctx, code)
+	if err != nil {
+		closeErr := hostModule.Close(ctx)
+		if closeErr != nil {
+			logger.Errorf("closing host module: %s", closeErr)
+		}
+		return nil, nil, fmt.Errorf("compiling guest module: %w", err)
+	}
+
+	c.hostModule, c.guestModule = hostModule, guestModule
+	return hostModule, guestModule, nil
+}
+
+func (c *compiledRuntime) compiled() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.guestModule != nil
+}
+
+// compiledRuntimeCache is a least recently used cache of compiled runtimes
+// keyed by the hash of their code. A compiled runtime is kept past its last
+// instance being stopped, and is closed once evicted and no longer used.
+type compiledRuntimeCache struct {
+	sync.Mutex
+	capacity   int
+	byCodeHash map[common.Hash]*compiledRuntime
+	// lru holds the compiled runtimes, the most recently used at the front.
+	lru *list.List
+}
+
+func newCompiledRuntimeCache(capacity int) *compiledRuntimeCache {
+	return &compiledRuntimeCache{
+		capacity:   capacity,
+		byCodeHash: make(map[common.Hash]*compiledRuntime),
+		lru:        list.New(),
+	}
+}
+
+// acquire returns the compiled runtime for the code with the given hash,
+// creating it if needed. Each call must be matched with a call to release
+// once the instance using the compiled runtime is stopped.
+func (c *compiledRuntimeCache) acquire(ctx context.Context, codeHash common.Hash) *compiledRuntime {
+	c.Lock()
+	defer c.Unlock()
+
+	compiled, ok := c.byCodeHash[codeHash]
+	if ok {
+		c.lru.MoveToFront(compiled.element)
+	} else {
+		compiled = &compiledRuntime{
+			codeHash: codeHash,
+			cache:    wazero.NewCompilationCache(),
+		}
+		compiled.element = c.lru.PushFront(compiled)
+		c.byCodeHash[codeHash] = compiled
+		c.evict(ctx)
+	}
+
+	compiled.instances++
+	return compiled
+}
+
+// release releases the compiled runtime used by a stopped instance. The
+// compiled runtime is closed if it was evicted and no other instance uses
+// it, or dropped if its code failed to compile.
+func (c *compiledRuntimeCache) release(ctx context.Context, compiled *compiledRuntime) error {
+	c.Lock()
+	defer c.Unlock()
+
+	compiled.instances--
+	if compiled.instances > 0 {
+		return nil
+	}
+
+	if compiled.element != nil {
+		if compiled.compiled() {
+			return nil
+		}
+		c.remove(compiled)
+	}
+
+	return compiled.cache.Close(ctx)
+}
+
+// evict evicts the least recently used compiled runtimes over the capacity,
+// closing the ones no instance uses.
+func (c *compiledRuntimeCache) evict(ctx context.Context) {
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back().Value.(*compiledRuntime)
+		c.remove(oldest)
+		if oldest.instances > 0 {
+			continue
+		}
+
+		err := oldest.cache.Close(ctx)
+		if err != nil {
+			logger.Errorf("closing the wazero compilation cache: %s", err)
+		}
+	}
+}
+
+func (c *compiledRuntimeCache) remove(compiled *compiledRuntime) {
+	c.lru.Remove(compiled.element)
+	compiled.element = nil
+	delete(c.byCodeHash, compiled.codeHash)
+}
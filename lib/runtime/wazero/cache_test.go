@@ -0,0 +1,171 @@
+// Copyright 2024 ChainSafe Systems (ON)
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package wazero_runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChainSafe/gossamer/internal/log"
+	"github.com/ChainSafe/gossamer/lib/common"
+	"github.com/ChainSafe/gossamer/lib/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+)
+
+type testCompiledModule struct {
+	wazero.CompiledModule
+}
+
+type closeCounter struct {
+	closed int
+}
+
+func (c *closeCounter) Close(context.Context) error {
+	c.closed++
+	return nil
+}
+
+func Test_compiledRuntimeCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// compiledModules marks the compiled runtime as compiled, without
+	// compiling anything, and records when its cache is closed.
+	compiledModules := func(compiled *compiledRuntime) *closeCounter {
+		counter := &closeCounter{}
+		compiled.cache = counter
+		compiled.guestModule = testCompiledModule{}
+		return counter
+	}
+
+	cache := newCompiledRuntimeCache(2)
+	hashA, hashB, hashC := common.Hash{1}, common.Hash{2}, common.Hash{3}
+
+	compiledA := cache.acquire(ctx, hashA)
+	closedA := compiledModules(compiledA)
+	require.NoError(t, cache.release(ctx, compiledA))
+
+	// the compiled runtime is kept once its last instance is stopped
+	assert.Same(t, compiledA, cache.acquire(ctx, hashA))
+	assert.Zero(t, closedA.closed)
+
+	compiledB := cache.acquire(ctx, hashB)
+	closedB := compiledModules(compiledB)
+	require.NoError(t, cache.release(ctx, compiledB))
+
+	// the least recently used compiled runtime is evicted, but only
+	// closed once the instance still using it is stopped
+	compiledC := cache.acquire(ctx, hashC)
+	compiledModules(compiledC)
+	assert.NotContains(t, cache.byCodeHash, hashA)
+	assert.Zero(t, closedA.closed)
+	require.NoError(t, cache.release(ctx, compiledA))
+	assert.Equal(t, 1, closedA.closed)
+
+	// an evicted compiled runtime no instance uses is closed right away
+	cache.acquire(ctx, hashA)
+	assert.NotContains(t, cache.byCodeHash, hashB)
+	assert.Equal(t, 1, closedB.closed)
+
+	// a compiled runtime whose code failed to compile is dropped
+	compiledD := cache.acquire(ctx, common.Hash{4})
+	closedD := &closeCounter{}
+	compiledD.cache = closedD
+	require.NoError(t, cache.release(ctx, compiledD))
+	assert.NotContains(t, cache.byCodeHash, common.Hash{4})
+	assert.Equal(t, 1, closedD.closed)
+}
+
+// callIndirectHostCode is a wasm module calling indirectly, through its
+// table, the ext_logging_max_level_version_1 host function it imports.
+var callIndirectHostCode = func() []byte {
+	code := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	// type section: () -> i32
+	code = append(code, 0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7f)
+	// import section: the host function and memory
+	code = append(code, 0x02, 0x35, 0x02, 0x03, 'e', 'n', 'v', 0x1f)
+	code = append(code, []byte("ext_logging_max_level_version_1")...)
+	code = append(code, 0x00, 0x00, 0x03, 'e', 'n', 'v', 0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00, 0x01)
+	// function section, table section and export of the function as "test"
+	code = append(code, 0x03, 0x02, 0x01, 0x00)
+	code = append(code, 0x04, 0x04, 0x01, 0x70, 0x00, 0x01)
+	code = append(code, 0x07, 0x08, 0x01, 0x04, 't', 'e', 's', 't', 0x00, 0x01)
+	// element section placing the host function in the table
+	code = append(code, 0x09, 0x07, 0x01, 0x00, 0x41, 0x00, 0x0b, 0x01, 0x00)
+	// code section: call_indirect of the table element 0
+	code = append(code, 0x0a, 0x09, 0x01, 0x07, 0x00, 0x41, 0x00, 0x11, 0x00, 0x00, 0x0b)
+	return code
+}()
+
+func Test_NewInstance_reusesCompiledRuntime(t *testing.T) {
+	config := Config{
+		LogLvl:         log.Critical,
+		DefaultVersion: &runtime.Version{},
+	}
+
+	callTest := func(t *testing.T, instance *Instance) {
+		t.Helper()
+		results, err := instance.Module.ExportedFunction("test").Call(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{4}, results)
+	}
+
+	first, err := NewInstance(callIndirectHostCode, config)
+	require.NoError(t, err)
+	second, err := NewInstance(callIndirectHostCode, config)
+	require.NoError(t, err)
+
+	compiled := first.metadata.compiled
+	assert.Same(t, compiled, second.metadata.compiled)
+	assert.Equal(t, first.metadata.guestModule, second.metadata.guestModule)
+	callTest(t, first)
+	callTest(t, second)
+
+	first.Stop()
+	second.Stop()
+
+	// the compiled modules are reused once all the instances are stopped
+	third, err := NewInstance(callIndirectHostCode, config)
+	require.NoError(t, err)
+	defer third.Stop()
+	assert.Same(t, compiled, third.metadata.compiled)
+	assert.Equal(t, first.metadata.guestModule, third.metadata.guestModule)
+	callTest(t, third)
+}
+
+func BenchmarkNewInstance(b *testing.B) {
+	runtimeFilepath, err := runtime.GetRuntime(context.Background(), runtime.WESTEND_RUNTIME_v0929)
+	require.NoError(b, err)
+	code, err := os.ReadFile(filepath.Clean(runtimeFilepath))
+	require.NoError(b, err)
+
+	config := Config{LogLvl: log.Critical}
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			instance, err := NewInstance(code, config)
+			require.NoError(b, err)
+			instance.Stop()
+		}
+	})
+
+	b.Run("overlapping", func(b *testing.B) {
+		previous, err := NewInstance(code, config)
+		require.NoError(b, err)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			instance, err := NewInstance(code, config)
+			require.NoError(b, err)
+			previous.Stop()
+			previous = instance
+		}
+		previous.Stop()
+	})
+}
@@ -38,7 +38,7 @@ type runtimeContextKeyType struct{
 
 type wazeroMeta struct {
 	config      wazero.RuntimeConfig
-	cache       wazero.CompilationCache
+	compiled    *compiledRuntime
 	guestModule wazero.CompiledModule
 }
 
@@ -50,6 +50,7 @@ type Instance struct {
 	wasmByteCode []byte
 	codeHash     common.Hash
 	metadata     wazeroMeta
+	stopped      bool
 	sync.Mutex
 }
 
@@ -110,12 +111,34 @@ func NewInstanceFromTrie(t trie.Trie, cfg Config) (*Instance, error) {
 func newRuntime(ctx context.Context,
 	code []byte,
 	config wazero.RuntimeConfig,
+	compiled *compiledRuntime,
 ) (api.Module, wazero.Runtime, wazero.CompiledModule, error) {
 	rt := wazero.NewRuntimeWithConfig(ctx, config)
 
+	hostCompiledModule, guestCompiledModule, err := compiled.modules(ctx, rt, code)
+	if err != nil {
+		return nil, nil, nil, closeOnError(ctx, rt, err)
+	}
+
+	_, err = rt.InstantiateModule(ctx, hostCompiledModule, wazero.NewModuleConfig())
+	if err != nil {
+		return nil, nil, nil, closeOnError(ctx, rt, fmt.Errorf("instantiating host module: %w", err))
+	}
+
+	mod, err := rt.InstantiateModule(ctx, guestCompiledModule, wazero.NewModuleConfig())
+	if err != nil {
+		return nil, nil, nil, closeOnError(ctx, rt, fmt.Errorf("instantiating guest module: %w", err))
+	}
+
+	return mod, rt, guestCompiledModule, nil
+}
+
+// compileHostModule compiles the env host module exporting the host
+// functions and the memory imported by the runtime code.
+func compileHostModule(ctx context.Context, rt wazero.Runtime) (wazero.CompiledModule, error) {
 	const i32, i64 = api.ValueTypeI32, api.ValueTypeI64
 
-	hostCompiledModule, err := rt.NewHostModuleBuilder("env").
+	return rt.NewHostModuleBuilder("env").
 		// values from newer kusama/polkadot runtimes
 		ExportMemory("memory", 23).
 		NewFunctionBuilder().
@@ -638,31 +661,16 @@ func newRuntime(ctx context.Context,
 		).
 		Export("ext_crypto_ecdsa_generate_version_1").
 		Compile(ctx)
+}
 
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	_, err = rt.InstantiateModule(ctx, hostCompiledModule, wazero.NewModuleConfig())
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	code, err = decompressWasm(code)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	guestCompiledModule, err := rt.CompileModule(ctx, code)
-	if err != nil {
-		return nil, nil, nil, err
+// closeOnError closes the runtime so a failed instantiation does not leak
+// its resources, and returns the given error.
+func closeOnError(ctx context.Context, rt wazero.Runtime, err error) error {
+	closeErr := rt.Close(ctx)
+	if closeErr != nil {
+		logger.Errorf("closing runtime: %s", closeErr)
 	}
-	mod, err := rt.Instantiate(ctx, code)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	return mod, rt, guestCompiledModule, nil
+	return err
 }
 
 // NewInstance instantiates a runtime from raw wasm bytecode
@@ -670,11 +678,24 @@ func NewInstance(code []byte, cfg Config) (instance *Instance, err error) {
 	logger.Debug("instantiating a runtime!")
 	logger.Patch(log.SetLevel(cfg.LogLvl), log.SetCallerFunc(true))
 
-	// Prepare a cache directory.
 	ctx := context.Background()
-	cache := wazero.NewCompilationCache()
-	config := wazero.NewRuntimeConfig().WithCompilationCache(cache)
-	mod, rt, guestCompiledModule, err := newRuntime(ctx, code, config)
+	compiledCodeHash, err := common.Blake2bHash(code)
+	if err != nil {
+		return nil, fmt.Errorf("hashing runtime code: %w", err)
+	}
+	compiled := compiledRuntimes.acquire(ctx, compiledCodeHash)
+	defer func() {
+		if err == nil {
+			return
+		}
+		releaseErr := compiledRuntimes.release(ctx, compiled)
+		if releaseErr != nil {
+			logger.Errorf("closing the wazero compilation cache: %s", releaseErr)
+		}
+	}()
+
+	config := wazero.NewRuntimeConfig().WithCompilationCache(compiled.cache)
+	mod, rt, guestCompiledModule, err := newRuntime(ctx, code, config, compiled)
 	if err != nil {
 		return nil, fmt.Errorf("creating runtime instance: %w", err)
 	}
@@ -695,7 +716,7 @@ func NewInstance(code []byte, cfg Config) (instance *Instance, err error) {
 		codeHash: cfg.CodeHash,
 		metadata: wazeroMeta{
 			config:      config,
-			cache:       cache,
+			compiled:    compiled,
 			guestModule: guestCompiledModule,
 		},
 	}
@@ -703,7 +724,7 @@ func NewInstance(code []byte, cfg Config) (instance *Instance, err error) {
 	if cfg.DefaultVersion == nil {
 		err = instance.version()
 		if err != nil {
-			return nil, fmt.Errorf("while getting runtime version: %w", err)
+			return nil, closeOnError(ctx, rt, fmt.Errorf("while getting runtime version: %w", err))
 		}
 	} else {
 		instance.Context.Version = cfg.DefaultVersion
@@ -747,7 +768,7 @@ func (i *Instance) Exec(function string, data []byte) ([]byte, error) {
 
 	memory := mod.Memory()
 	if memory == nil {
-		panic("nil memory")
+		return nil, fmt.Errorf("wazero error: nil memory for guest module")
 	}
 
 	dataLength := uint32(len(data))
@@ -1169,15 +1190,22 @@ func (in *Instance) SetContextStorage(s runtime.Storage) {
 
 // Stop closes the WASM instance, its imports and clears
 // the context allocator in a thread-safe way.
+// The instance cannot be used once stopped, and stopping it again is a no-op.
 func (in *Instance) Stop() {
 	in.Lock()
 	defer in.Unlock()
+
+	if in.stopped {
+		return
+	}
+	in.stopped = true
+
 	err := in.Runtime.Close(context.Background())
 	if err != nil {
 		log.Errorf("runtime failed to close: %v", err)
 	}
 
-	err = in.metadata.cache.Close(context.Background())
+	err = compiledRuntimes.release(context.Background(), in.metadata.compiled)
 	if err != nil {
 		log.Errorf("closing the wazero compilation cache: %v", err)
 	}
@@ -9,6 +9,7 @@
 	"math/big"
 	"os"
 	"path/filepath"
+	goruntime "runtime"
 	"testing"
 
 	"github.com/ChainSafe/gossamer/dot/network"
@@ -78,6 +79,69 @@ func newTrieFromKeyValueList(t *testing.T, filename string) trie.Trie {
 // https://github.com/paritytech/substrate/blob/ded44948e2d5a398abcb4e342b0513cb690961bb/frame/grandpa/src/benchmarking.rs#L85
 var testKeyOwnershipProof types.OpaqueKeyOwnershipProof = types.OpaqueKeyOwnershipProof([]byte{64, 138, 252, 29, 127, 102, 189, 129, 207, 47, 157, 60, 17, 138, 194, 121, 139, 92, 176, 175, 224, 16, 185, 93, 175, 251, 224, 81, 209, 61, 0, 71}) //nolint:lll
 
+func Test_NewInstance_invalidCode(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		code     []byte
+		errMatch string
+	}{
+		"empty_code": {
+			errMatch: "creating runtime instance: compiling guest module",
+		},
+		"truncated_header": {
+			code:     []byte{0x00, 0x61, 0x73},
+			errMatch: "creating runtime instance: compiling guest module",
+		},
+		"truncated_section": {
+			// wasm magic and version followed by a type section
+			// announcing more bytes than it contains
+			code:     []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x10, 0x01},
+			errMatch: "creating runtime instance: compiling guest module",
+		},
+		"invalid_compressed_code": {
+			code:     []byte{82, 188, 83, 118, 70, 219, 142, 5, 0xff},
+			errMatch: "creating runtime instance: decompressing wasm code",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			instance, err := NewInstance(testCase.code, Config{})
+			require.ErrorContains(t, err, testCase.errMatch)
+			assert.Nil(t, instance)
+		})
+	}
+}
+
+func TestInstance_Stop(t *testing.T) {
+	// empty wasm module, the runtime version is given since it
+	// does not export the Core_version function.
+	code := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	config := Config{
+		LogLvl:         log.Critical,
+		DefaultVersion: &runtime.Version{},
+	}
+
+	goroutinesBefore := goruntime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		instance, err := NewInstance(code, config)
+		require.NoError(t, err)
+
+		instance.Stop()
+		instance.Stop()
+
+		_, err = instance.Exec(runtime.CoreVersion, nil)
+		require.Error(t, err)
+	}
+
+	assert.LessOrEqual(t, goruntime.NumGoroutine(), goroutinesBefore)
+}
+
 func Test_Instance_Version(t *testing.T) {
 	type instanceVersioner interface {
 		Version() (runtime.Version, error)
@@ -4,6 +4,8 @@
 package inmemory
 
 import (
+	"sync"
+
 	lrucache "github.com/ChainSafe/gossamer/lib/utils/lru-cache"
 	"github.com/ChainSafe/gossamer/pkg/trie/cache"
 )
@@ -14,6 +16,9 @@
 
 // TrieInMemoryCache is an in-memory cache for trie nodes
 type TrieInMemoryCache struct {
+	// mtx guards both caches so the node and the value of a key are read and
+	// written together by GetNodeAndValue and SetNodeAndValue.
+	mtx        sync.Mutex
 	nodeCache  *lrucache.LRUCache[string, []byte]
 	valueCache *maxBytesLRUCache
 }
@@ -28,22 +33,54 @@ func NewTrieInMemoryCache() *TrieInMemoryCache {
 
 // GetValue returns the value for the given key
 func (tc *TrieInMemoryCache) GetValue(key []byte) []byte {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+
 	return tc.valueCache.get(string(key))
 }
 
 // SetValue sets the value for the given key
 func (tc *TrieInMemoryCache) SetValue(key []byte, value []byte) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+
 	tc.valueCache.set(string(key), value)
 }
 
 // GetNode returns the node for the given key
 func (tc *TrieInMemoryCache) GetNode(key []byte) []byte {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+
 	return tc.nodeCache.Get(string(key))
 }
 
 // SetNode sets the node for the given key
 func (tc *TrieInMemoryCache) SetNode(key, value []byte) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+
 	tc.nodeCache.Put(string(key), value)
 }
 
+// GetNodeAndValue returns both the node and the value for the given key,
+// each being nil if it is not cached.
+func (tc *TrieInMemoryCache) GetNodeAndValue(key []byte) (node, value []byte) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+
+	keyString := string(key)
+	return tc.nodeCache.Get(keyString), tc.valueCache.get(keyString)
+}
+
+// SetNodeAndValue sets both the node and the value for the given key
+func (tc *TrieInMemoryCache) SetNodeAndValue(key, node, value []byte) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+
+	keyString := string(key)
+	tc.nodeCache.Put(keyString, node)
+	tc.valueCache.set(keyString, value)
+}
+
 var _ cache.TrieCache = (*TrieInMemoryCache)(nil)
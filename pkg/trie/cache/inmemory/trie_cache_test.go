@@ -4,6 +4,8 @@
 package inmemory
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,3 +29,84 @@ func Test_TrieCache_SetAndGet(t *testing.T) {
 		assert.Nil(t, valueFromCache)
 	})
 }
+
+func Test_TrieCache_NodeAndValue(t *testing.T) {
+	t.Run("set_and_get_node_and_value", func(t *testing.T) {
+		cache := NewTrieInMemoryCache()
+		key := []byte("key")
+
+		cache.SetNodeAndValue(key, []byte("node"), []byte("value"))
+		node, value := cache.GetNodeAndValue(key)
+
+		assert.Equal(t, []byte("node"), node)
+		assert.Equal(t, []byte("value"), value)
+		assert.Equal(t, []byte("node"), cache.GetNode(key))
+		assert.Equal(t, []byte("value"), cache.GetValue(key))
+	})
+
+	t.Run("get_only_node_cached", func(t *testing.T) {
+		cache := NewTrieInMemoryCache()
+		key := []byte("key")
+
+		cache.SetNode(key, []byte("node"))
+		node, value := cache.GetNodeAndValue(key)
+
+		assert.Equal(t, []byte("node"), node)
+		assert.Nil(t, value)
+	})
+
+	t.Run("get_not_found", func(t *testing.T) {
+		cache := NewTrieInMemoryCache()
+		node, value := cache.GetNodeAndValue([]byte("missing"))
+
+		assert.Nil(t, node)
+		assert.Nil(t, value)
+	})
+}
+
+func Test_TrieCache_NodeAndValue_concurrent(t *testing.T) {
+	cache := NewTrieInMemoryCache()
+	key := []byte("key")
+	cache.SetNodeAndValue(key, []byte("node0"), []byte("value0"))
+
+	const writes = 1000
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= writes; i++ {
+			cache.SetNodeAndValue(key, []byte(fmt.Sprintf("node%d", i)), []byte(fmt.Sprintf("value%d", i)))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			// the node and value read are always from the same write
+			node, value := cache.GetNodeAndValue(key)
+			assert.Equal(t, string(node[len("node"):]), string(value[len("value"):]))
+		}
+	}()
+
+	wg.Wait()
+}
+
+func Benchmark_TrieCache_GetNodeAndValue(b *testing.B) {
+	cache := NewTrieInMemoryCache()
+	key := []byte("key")
+	cache.SetNodeAndValue(key, []byte("node"), []byte("value"))
+
+	b.Run("separate_calls", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = cache.GetNode(key)
+			_ = cache.GetValue(key)
+		}
+	})
+
+	b.Run("combined_call", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = cache.GetNodeAndValue(key)
+		}
+	})
+}
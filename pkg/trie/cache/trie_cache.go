@@ -8,4 +8,6 @@ type TrieCache interface {
 	SetValue(key, value []byte)
 	GetNode(key []byte) []byte
 	SetNode(key, value []byte)
+	GetNodeAndValue(key []byte) (node, value []byte)
+	SetNodeAndValue(key, node, value []byte)
 }
@@ -146,3 +146,42 @@ func (t *InMemoryTrie) ClearFromChild(keyToChild, key []byte) error {
 
 	return t.SetChild(keyToChild, child)
 }
+
+// CopyChild returns a copy of the child trie located at key :child_storage:[keyToChild].
+// Changes made to the copy do not affect this trie until it is set back with ReplaceChild.
+func (t *InMemoryTrie) CopyChild(keyToChild []byte) (trie.Trie, error) {
+	child, err := t.getInternalChildTrie(keyToChild)
+	if err != nil {
+		return nil, err
+	}
+
+	return child.Snapshot(), nil
+}
+
+// ReplaceChild replaces the child trie located at key :child_storage:[keyToChild]
+// with the given child trie, deleting the child entry if the new child trie is empty.
+func (t *InMemoryTrie) ReplaceChild(keyToChild []byte, child trie.Trie) error {
+	newChild, ok := child.(*InMemoryTrie)
+	if !ok {
+		return fmt.Errorf("child trie type %T is not supported", child)
+	}
+
+	origChild, err := t.getInternalChildTrie(keyToChild)
+	if err != nil && !errors.Is(err, trie.ErrChildTrieDoesNotExist) {
+		return fmt.Errorf("getting child: %w", err)
+	}
+
+	if origChild != nil {
+		origChildHash, err := origChild.Hash()
+		if err != nil {
+			return err
+		}
+		delete(t.childTries, origChildHash)
+	}
+
+	if newChild.root == nil {
+		return t.DeleteChild(keyToChild)
+	}
+
+	return t.SetChild(keyToChild, newChild)
+}
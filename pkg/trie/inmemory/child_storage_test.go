@@ -60,6 +60,45 @@ func TestPutAndClearFromChild(t *testing.T) {
 	assert.Equal(t, []uint8(nil), value)
 }
 
+func TestCopyAndReplaceChild(t *testing.T) {
+	childKey := []byte("default")
+	keyInChild := []byte{0x01, 0x35}
+	parentTrie := NewEmptyTrie()
+
+	err := parentTrie.SetChild(childKey, buildSmallTrie())
+	require.NoError(t, err)
+	originalRoot := parentTrie.MustHash()
+
+	childCopy, err := parentTrie.CopyChild(childKey)
+	require.NoError(t, err)
+
+	err = childCopy.Delete(keyInChild)
+	require.NoError(t, err)
+
+	// the parent trie is unchanged until the copy is set back
+	value, err := parentTrie.GetFromChild(childKey, keyInChild)
+	require.NoError(t, err)
+	assert.NotNil(t, value)
+	assert.Equal(t, originalRoot, parentTrie.MustHash())
+
+	err = parentTrie.ReplaceChild(childKey, childCopy)
+	require.NoError(t, err)
+
+	value, err = parentTrie.GetFromChild(childKey, keyInChild)
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	assert.NotEqual(t, originalRoot, parentTrie.MustHash())
+	assert.Len(t, parentTrie.childTries, 1)
+
+	// replacing with an empty child trie deletes the child
+	err = parentTrie.ReplaceChild(childKey, NewEmptyTrie())
+	require.NoError(t, err)
+
+	_, err = parentTrie.GetChild(childKey)
+	assert.ErrorIs(t, err, trie.ErrChildTrieDoesNotExist)
+	assert.Empty(t, parentTrie.childTries)
+}
+
 func TestPutAndGetFromChild(t *testing.T) {
 	childKey := []byte("default")
 	childTrie := buildSmallTrie()
@@ -100,6 +100,19 @@ func (t *InMemoryTrie) Snapshot() (newTrie *InMemoryTrie) {
 	}
 }
 
+// SnapshotKeepingDeltas creates a copy on write snapshot of the trie as
+// Snapshot does, but keeps the deleted node hashes tracked since the last
+// snapshot, so they are still reported by GetChangedNodeHashes.
+// It is used to capture the trie in the middle of a block.
+func (t *InMemoryTrie) SnapshotKeepingDeltas() (newTrie *InMemoryTrie) {
+	newTrie = t.Snapshot()
+	newTrie.deltas.MergeWith(t.deltas)
+	for rootHash, childTrie := range t.childTries {
+		newTrie.childTries[rootHash].deltas.MergeWith(childTrie.deltas)
+	}
+	return newTrie
+}
+
 // HandleTrackedDeltas sets the pending deleted node hashes in
 // the trie deltas tracker if and only if success is true.
 func (t *InMemoryTrie) HandleTrackedDeltas(success bool, pendingDeltas tracking.Getter) {
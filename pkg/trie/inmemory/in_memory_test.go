@@ -106,6 +106,33 @@ func Test_Trie_Snapshot(t *testing.T) {
 	assert.Equal(t, expectedTrie.childTries, newTrie.childTries)
 }
 
+func Test_Trie_SnapshotKeepingDeltas(t *testing.T) {
+	t.Parallel()
+
+	trie := &InMemoryTrie{
+		generation: 8,
+		root:       &node.Node{PartialKey: []byte{8}, StorageValue: []byte{1}},
+		childTries: map[common.Hash]*InMemoryTrie{
+			{1}: {
+				generation: 1,
+				root:       &node.Node{PartialKey: []byte{1}, StorageValue: []byte{1}},
+				deltas:     newDeltas("0x02"),
+			},
+		},
+		deltas: newDeltas("0x01"),
+	}
+
+	newTrie := trie.SnapshotKeepingDeltas()
+
+	assert.Equal(t, uint64(9), newTrie.generation)
+	assert.Equal(t, newDeltas("0x01"), newTrie.deltas)
+	assert.Equal(t, newDeltas("0x02"), newTrie.childTries[common.Hash{1}].deltas)
+
+	// the deltas are copied and not shared with the snapshotted trie
+	newTrie.deltas.RecordDeleted(common.Hash{3})
+	assert.Equal(t, newDeltas("0x01"), trie.deltas)
+}
+
 func Test_Trie_handleTrackedDeltas(t *testing.T) {
 	t.Parallel()
 
@@ -30,8 +30,21 @@ func Generate(rootHash []byte, fullKeys [][]byte, database db.DBGetter) (
 	if err := trie.Load(database, common.BytesToHash(rootHash)); err != nil {
 		return nil, fmt.Errorf("loading trie: %w", err)
 	}
-	rootNode := trie.RootNode()
 
+	return generate(trie.RootNode(), fullKeys)
+}
+
+// GenerateFromTrie generates and deduplicates the encoded proof nodes
+// for the in-memory trie given, and for the slice of (Little Endian)
+// full keys given. Since the trie nodes are already in memory, no
+// database is needed to load them.
+func GenerateFromTrie(trie *inmemory.InMemoryTrie, fullKeys [][]byte) (
+	encodedProofNodes [][]byte, err error) {
+	return generate(trie.RootNode(), fullKeys)
+}
+
+func generate(rootNode *node.Node, fullKeys [][]byte) (
+	encodedProofNodes [][]byte, err error) {
 	buffer := pools.DigestBuffers.Get().(*bytes.Buffer)
 	defer pools.DigestBuffers.Put(buffer)
 
@@ -23,6 +23,8 @@ type ChildTriesWrite interface {
 	PutIntoChild(keyToChild, key, value []byte) error
 	DeleteChild(keyToChild []byte) (err error)
 	ClearFromChild(keyToChild, key []byte) error
+	CopyChild(keyToChild []byte) (Trie, error)
+	ReplaceChild(keyToChild []byte, child Trie) error
 }
 
 type KVStoreRead interface {